@@ -2,18 +2,55 @@
 package main
 
 import (
+	"cmp"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 
 	"github.com/ian-howell/treemux/internal/cli"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// Exit statuses, documented in the README so that scripts and tmux bindings can react to them.
+// Invalid flags exit 2, as the flag package does.
+const (
+	exitError           = 1
+	exitNoSessions      = 3
+	exitTmuxNotRunning  = 4
+	exitNotRepository   = 5
+	exitSessionNotFound = 6
+	exitCanceled        = 130
 )
 
 // main runs the treemux CLI and exits on error.
 func main() {
-	if err := run(); err != nil {
+	err := run()
+	if err == nil {
+		return
+	}
+	// Dismissing the picker is not worth a message, only a status.
+	if !errors.Is(err, treemux.ErrCanceled) {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	}
+	os.Exit(exitCode(err))
+}
+
+// exitCode returns the exit status for err.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, treemux.ErrCanceled):
+		return exitCanceled
+	case errors.Is(err, treemux.ErrNoSessions):
+		return exitNoSessions
+	case errors.Is(err, treemux.ErrTmuxNotRunning):
+		return exitTmuxNotRunning
+	case errors.Is(err, treemux.ErrNotRepository):
+		return exitNotRepository
+	case errors.Is(err, treemux.ErrSessionNotFound):
+		return exitSessionNotFound
+	default:
+		return exitError
 	}
 }
 
@@ -21,12 +58,47 @@ func main() {
 func run() error {
 	var (
 		// configFilePath = flag.String("config-file", "", "Path to a treemux configuration file.")
-		useFullscreen = flag.Bool("fullscreen", false, "Whether to use full-screen mode for the prompter.")
+		useFullscreen   = flag.Bool("fullscreen", false, "Whether to use full-screen mode for the prompter.")
+		prompter        = flag.String("prompter", "huh", "Prompter to use: huh (flat list), tree (grouped by repository) or exec (an external selector).")
+		prompterCommand = flag.String("prompter-command", "", "Selector for the exec prompter, run with sh -c, e.g. 'rofi -dmenu'. It reads labels on stdin and prints the chosen one.")
+		prompterIndex   = flag.Bool("prompter-index", false, "Read the index of the chosen line from the exec prompter's selector, e.g. for 'rofi -dmenu -format i'.")
+		showWindows     = flag.Bool("windows", false, "Whether to list each session's windows beneath it.")
+		cacheTTL        = flag.Duration("cache-ttl", 0, "Cache lister results and refresh them in the background once older than this. Zero disables the cache.")
+		execTimeout     = flag.Duration("exec-timeout", 0, "How long an --exec-lister plugin may run. Zero uses the default of 5s.")
+		restoreCommands = flag.Bool("restore-commands", false, "Rerun the saved foreground commands of sessions restored from a snapshot or a plugin's template.")
+		fromStdin       = flag.Bool("from-stdin", false, "List the directories, session names or JSON objects read from stdin, one per line, instead of tmux sessions.")
+		printFormat     = flag.String("print-format", "", "Format for --print, which it implies: name, path, json, or a Go template such as '{{.Path}}'.")
+		dryRun          = flag.Bool("dry-run", false, "Print the tmux commands that would change tmux instead of running them.")
+		debug           = flag.Bool("debug", false, "Log every tmux and git command to stderr. Set TREEMUX_LOG to a file to log there instead.")
+		record          = flag.String("record", "", "Write a transcript of every tmux and git command and its result to this file.")
+		replay          = flag.String("replay", "", "Answer tmux and git commands from this transcript instead of running them.")
 	)
+	var printing printFlag
+	flag.Var(&printing, "print", "Print the selected session to stdout instead of attaching to it. Takes an optional format, as in --print='{{.Path}}'; see --print-format.")
+	var execListers []string
+	flag.Func("exec-lister", "Plugin command, run with sh -c, that prints sessions as JSON lines. May be repeated.", func(command string) error {
+		execListers = append(execListers, command)
+		return nil
+	})
 	flag.Parse()
 
 	config := cli.Config{
-		FullScreen: *useFullscreen,
+		FullScreen:      *useFullscreen,
+		Prompter:        *prompter,
+		PrompterCommand: *prompterCommand,
+		PrompterIndex:   *prompterIndex,
+		Windows:         *showWindows,
+		CacheTTL:        *cacheTTL,
+		ExecListers:     execListers,
+		ExecTimeout:     *execTimeout,
+		RestoreCommands: *restoreCommands,
+		FromStdin:       *fromStdin,
+		Print:           printing.enabled,
+		PrintFormat:     cmp.Or(printing.format, *printFormat),
+		DryRun:          *dryRun,
+		Debug:           *debug,
+		Record:          *record,
+		Replay:          *replay,
 	}
 	// if *configFilePath != "" {
 	// 	var err error
@@ -36,9 +108,38 @@ func run() error {
 	// 	}
 	// }
 
-	if err := cli.Run(config); err != nil {
+	if err := cli.Run(config, flag.Args()); err != nil {
 		return fmt.Errorf("running treemux: %w", err)
 	}
 
 	return nil
 }
+
+// printFlag is --print, which takes an optional format: --print or --print=FORMAT. Like a boolean
+// flag it never takes the next argument as its value, so that a bare --print cannot swallow a
+// subcommand; a format has to be joined to it with '='.
+type printFlag struct {
+	enabled bool
+	format  string
+}
+
+func (p *printFlag) String() string {
+	return p.format
+}
+
+// IsBoolFlag lets --print be given without a value.
+func (p *printFlag) IsBoolFlag() bool {
+	return true
+}
+
+func (p *printFlag) Set(value string) error {
+	switch value {
+	case "true":
+		p.enabled, p.format = true, ""
+	case "false":
+		p.enabled, p.format = false, ""
+	default:
+		p.enabled, p.format = true, value
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestPrintFlag(t *testing.T) {
+	tests := []struct {
+		args        []string
+		wantEnabled bool
+		wantFormat  string
+		wantArgs    []string
+	}{
+		{args: nil},
+		{args: []string{"--print"}, wantEnabled: true},
+		{args: []string{"--print=path"}, wantEnabled: true, wantFormat: "path"},
+		{args: []string{"--print={{.Path}}"}, wantEnabled: true, wantFormat: "{{.Path}}"},
+		// A bare --print never takes the next argument, which may be a subcommand.
+		{args: []string{"--print", "list"}, wantEnabled: true, wantArgs: []string{"list"}},
+		{args: []string{"--print=true"}, wantEnabled: true},
+		{args: []string{"--print=path", "--print=false"}},
+	}
+	for _, test := range tests {
+		flags := flag.NewFlagSet("treemux", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		var printing printFlag
+		flags.Var(&printing, "print", "")
+		if err := flags.Parse(test.args); err != nil {
+			t.Fatalf("%q: %v", test.args, err)
+		}
+		if printing.enabled != test.wantEnabled || printing.format != test.wantFormat {
+			t.Errorf("%q: expected %t with %q, got %t with %q", test.args, test.wantEnabled, test.wantFormat, printing.enabled, printing.format)
+		}
+		if !slices.Equal(flags.Args(), test.wantArgs) {
+			t.Errorf("%q: expected arguments %q, got %q", test.args, test.wantArgs, flags.Args())
+		}
+	}
+}
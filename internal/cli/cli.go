@@ -5,27 +5,209 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
+	"github.com/ian-howell/treemux/internal/executor"
+	"github.com/ian-howell/treemux/internal/history"
 	"github.com/ian-howell/treemux/internal/listers"
 	"github.com/ian-howell/treemux/internal/prompters"
 	"github.com/ian-howell/treemux/internal/tmux"
+	"github.com/ian-howell/treemux/internal/tmuxfake"
 	"github.com/ian-howell/treemux/internal/treemux"
 )
 
-// Run parses CLI args and executes the requested command.
-func Run(config Config) error {
+// Run parses CLI args and executes the requested command. With no arguments it runs the session
+// picker.
+func Run(config Config, args []string) error {
 	// TODO: Handle config
-	tmuxClient := tmux.New()
-	app, err := treemux.New(
-		treemux.WithPrompter(&prompters.Huh{
-			FullScreen: config.FullScreen,
-		}),
-		treemux.WithListers([]treemux.Lister{
-			listers.NewActiveSessions(tmuxClient),
-		}),
-	)
+	cleanup, err := setupTracing(config)
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+	var options []tmux.Option
+	if client, ok := popupClient(); ok {
+		// A popup is not a tmux client, so act on the client it was opened for, and fill it.
+		options = append(options, tmux.WithClient(client))
+		config.FullScreen = true
+	}
+	tmuxClient := tmux.New(options...)
+	if config.DryRun {
+		tmuxClient = dryRunClient(tmuxClient, options)
+	}
+	if len(args) == 0 {
+		return runPicker(config, tmuxClient)
+	}
+	switch args[0] {
+	case "worktree":
+		return runWorktree(config, tmuxClient, args[1:])
+	case "back", "forward", "last":
+		return runJump(config, tmuxClient, args[0])
+	case "popup":
+		return runPopup(config, tmuxClient, args[1:])
+	case "daemon":
+		return runDaemon(config, tmuxClient, args[1:])
+	case "history":
+		return runHistory(tmuxClient, args[1:])
+	case "init":
+		return runInit(tmuxClient, args[1:])
+	case "cache":
+		return runCache(args[1:])
+	case "list":
+		return runList(tmuxClient, args[1:])
+	case "here":
+		return runHere(tmuxClient, args[1:])
+	case "open":
+		return runOpen(config, tmuxClient, args[1:])
+	case "save":
+		return runSave(tmuxClient, args[1:])
+	case "restore":
+		return runRestore(tmuxClient, args[1:])
+	case "shell-init":
+		return runShellInit(args[1:])
+	case "doctor":
+		return runDoctor(config, tmuxClient, args[1:])
+	default:
+		if format, ok := printFormatArg(config, args); ok {
+			config.PrintFormat = format
+			return runPicker(config, tmuxClient)
+		}
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// printFormatArg returns the format given after a bare --print, as in `--print '{{.Path}}'`. The
+// flag package leaves it as an argument, since --print may also be given without a format. Only
+// the named formats and templates are taken, so that a mistyped subcommand is still reported.
+func printFormatArg(config Config, args []string) (string, bool) {
+	if !config.Print || config.PrintFormat != "" || len(args) != 1 {
+		return "", false
+	}
+	switch format := args[0]; format {
+	case "name", "path", "json":
+		return format, true
+	default:
+		return format, strings.Contains(format, "{{")
+	}
+}
+
+// dryRunClient returns a client that acts on a model of the server tmuxClient talks to, seeded
+// from it, and prints the commands that change the model.
+func dryRunClient(tmuxClient *tmux.Client, options []tmux.Option) *tmux.Client {
+	model := &tmuxfake.Server{Out: os.Stdout, Current: tmuxClient.ClientTTY()}
+	model.Seed(tmuxClient)
+	return tmux.New(append(options, tmux.WithRunner(model))...)
+}
+
+// runPicker lists sessions, prompts for one and attaches to it, or prints it with --print.
+func runPicker(config Config, tmuxClient *tmux.Client) error {
+	withPrompter, err := prompterOption(config)
+	if err != nil {
+		return err
+	}
+	var candidates []treemux.Lister
+	if config.FromStdin {
+		// Piped candidates never change, so there is nothing to watch.
+		if candidates, err = stdinListers(config, tmuxClient); err != nil {
+			return err
+		}
+	} else {
+		changes, stopChanges := sessionChanges(config, tmuxClient)
+		defer stopChanges()
+		candidates = pickerListers(config, tmuxClient, changes)
+	}
+	opts := []treemux.Option{
+		withPrompter,
+		treemux.WithListers(candidates),
+		treemux.WithLastSession(tmuxClient.LastSession),
+	}
+	if !config.DryRun {
+		opts = append(opts, treemux.WithHistory(history.Recorder{Client: tmuxClient.ClientTTY()}))
+	}
+	if config.Print || config.PrintFormat != "" {
+		printer, err := treemux.NewPrinter(os.Stdout, config.PrintFormat)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, treemux.WithPrinter(printer))
+	}
+	app, err := treemux.New(opts...)
 	if err != nil {
 		return fmt.Errorf("creating app: %w", err)
 	}
 	return app.Run()
 }
+
+// pickerListers returns the listers of the picker. Listers that follow changes are listed again on
+// every signal from changes.
+func pickerListers(config Config, tmuxClient *tmux.Client, changes <-chan struct{}) []treemux.Lister {
+	pickerListers := []treemux.Lister{
+		counted(tmuxClient, func(tmuxClient *tmux.Client, _ *executor.Executor) treemux.Lister {
+			return sessionLister(config, tmuxClient, changes)
+		}),
+		counted(tmuxClient, func(tmuxClient *tmux.Client, _ *executor.Executor) treemux.Lister {
+			saved := listers.NewSnapshot(tmuxClient)
+			saved.Restore.Commands = config.RestoreCommands
+			return saved
+		}),
+	}
+	for _, command := range config.ExecListers {
+		pickerListers = append(pickerListers, counted(tmuxClient, func(tmuxClient *tmux.Client, e *executor.Executor) treemux.Lister {
+			plugin := listers.NewExec(tmuxClient, []string{"sh", "-c", command})
+			if config.ExecTimeout > 0 {
+				plugin.Timeout = config.ExecTimeout
+			}
+			plugin.Restore.Commands = config.RestoreCommands
+			plugin.Executor = e
+			return cached(config, plugin, "exec "+command)
+		}))
+	}
+	return pickerListers
+}
+
+// counted builds a lister whose commands run with an executor of its own, so that the debug log
+// counts them apart from the commands of other listers.
+func counted(tmuxClient *tmux.Client, build func(*tmux.Client, *executor.Executor) treemux.Lister) treemux.Lister {
+	e := executor.Default().Fork()
+	return listers.NewCounted(build(tmuxClient.With(tmux.WithExecutor(e)), e), e)
+}
+
+// prompterOption configures the prompter selected by the config.
+func prompterOption(config Config) (treemux.Option, error) {
+	switch config.Prompter {
+	case "", "huh":
+		return treemux.WithPrompter(&prompters.Huh{FullScreen: config.FullScreen}), nil
+	case "tree":
+		return treemux.WithPrompter(&prompters.Tree{FullScreen: config.FullScreen}), nil
+	case "exec":
+		if config.PrompterCommand == "" {
+			return nil, fmt.Errorf("the exec prompter needs --prompter-command")
+		}
+		return treemux.WithPrompter(&prompters.Exec{
+			Command: []string{"sh", "-c", config.PrompterCommand},
+			Index:   config.PrompterIndex,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown prompter %q", config.Prompter)
+	}
+}
+
+// cached wraps the lister with the lister cache when it is enabled. The key must describe the
+// lister's configuration.
+func cached(config Config, lister treemux.Lister, key string) treemux.Lister {
+	if config.CacheTTL <= 0 {
+		return lister
+	}
+	cache := listers.NewCached(lister, key, config.CacheTTL)
+	cache.ReadOnly = config.DryRun
+	return cache
+}
+
+// runCache manages the lister cache.
+func runCache(args []string) error {
+	if len(args) != 1 || args[0] != "clear" {
+		return fmt.Errorf("usage: treemux cache clear")
+	}
+	return listers.ClearCache()
+}
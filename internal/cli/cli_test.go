@@ -0,0 +1,28 @@
+package cli
+
+import "testing"
+
+func TestPrintFormatArg(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		args   []string
+		want   string
+		wantOK bool
+	}{
+		{name: "template", config: Config{Print: true}, args: []string{"{{.Path}}"}, want: "{{.Path}}", wantOK: true},
+		{name: "named format", config: Config{Print: true}, args: []string{"json"}, want: "json", wantOK: true},
+		{name: "mistyped subcommand", config: Config{Print: true}, args: []string{"lsit"}},
+		{name: "without --print", args: []string{"{{.Path}}"}},
+		{name: "format already given", config: Config{Print: true, PrintFormat: "name"}, args: []string{"path"}},
+		{name: "more arguments", config: Config{Print: true}, args: []string{"path", "extra"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			format, ok := printFormatArg(test.config, test.args)
+			if ok != test.wantOK || ok && format != test.want {
+				t.Fatalf("expected %q, %t, got %q, %t", test.want, test.wantOK, format, ok)
+			}
+		})
+	}
+}
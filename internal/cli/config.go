@@ -3,6 +3,7 @@ package cli
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -11,6 +12,57 @@ import (
 type Config struct {
 	// FullScreen determines whether the prompter should be displayed in full-screen mode.
 	FullScreen bool
+
+	// Prompter selects the prompter: "huh" for a flat list, "tree" for sessions grouped by
+	// repository and worktree, or "exec" for an external selector such as dmenu or rofi.
+	Prompter string
+
+	// PrompterCommand is the selector run by the exec prompter, with sh -c.
+	PrompterCommand string
+
+	// PrompterIndex makes the exec prompter read the index of the chosen line instead of the line.
+	PrompterIndex bool
+
+	// Windows lists each session's windows beneath it.
+	Windows bool
+
+	// CacheTTL enables the lister cache. Cached results are shown immediately and refreshed in the
+	// background once they are older than the TTL. Zero disables caching.
+	CacheTTL time.Duration
+
+	// ExecListers are plugin commands, run with sh -c, that print sessions as JSON lines. See
+	// listers.ExecEntry for the format.
+	ExecListers []string
+
+	// ExecTimeout bounds each run of a plugin. Zero uses listers.DefaultExecTimeout.
+	ExecTimeout time.Duration
+
+	// RestoreCommands reruns the saved foreground commands of sessions the picker restores from the
+	// snapshot or creates from a plugin's template.
+	RestoreCommands bool
+
+	// FromStdin lists the directories, session names or JSON objects read from stdin instead of
+	// the usual listers.
+	FromStdin bool
+
+	// Print writes the selected session to stdout instead of attaching to it.
+	Print bool
+
+	// PrintFormat is the treemux.NewPrinter format of Print. Setting it implies Print.
+	PrintFormat string
+
+	// DryRun answers tmux queries from a model of the running server and prints the commands that
+	// would change it instead of running them.
+	DryRun bool
+
+	// Debug logs every tmux and git command, and how long each lister took, to stderr.
+	Debug bool
+
+	// Record, if set, is a file to write a transcript of every command and its result to.
+	Record string
+
+	// Replay, if set, is a transcript to answer commands from instead of running them.
+	Replay string
 }
 
 // LoadConfig loads treemux configuration from the specified file path.
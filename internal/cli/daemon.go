@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ian-howell/treemux/internal/daemon"
+	"github.com/ian-howell/treemux/internal/listers"
+	"github.com/ian-howell/treemux/internal/tmux"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// runDaemon serves the session index on the daemon socket until interrupted.
+func runDaemon(config Config, tmuxClient *tmux.Client, args []string) error {
+	flags := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	interval := flags.Duration("interval", 2*time.Second, "How often to rebuild the session index.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	activeSessions := listers.NewActiveSessions(tmuxClient)
+	activeSessions.IncludeWindows = config.Windows
+	server := daemon.NewServer(activeSessions, tmuxClient, *interval)
+	server.Options = daemon.IndexOptions{Windows: config.Windows}
+	changes, stopChanges := sessionChanges(config, tmuxClient)
+	defer stopChanges()
+	server.Changes = changes
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return server.ListenAndServe(ctx, daemon.SocketPath())
+}
+
+// sessionLister returns the lister for the picker: the daemon's index when a daemon is running
+// with the same lister options, and otherwise the active sessions listed directly. Either is
+// listed again on every change. The daemon keeps no cache of its own, so --cache-ttl always lists
+// directly.
+func sessionLister(config Config, tmuxClient *tmux.Client, changes <-chan struct{}) treemux.Lister {
+	activeSessions := listers.NewActiveSessions(tmuxClient)
+	activeSessions.IncludeWindows = config.Windows
+	if client, err := daemon.Dial(daemon.SocketPath()); err == nil {
+		if config.CacheTTL <= 0 && client.Options() == (daemon.IndexOptions{Windows: config.Windows}) {
+			indexed := listers.NewDaemon(client, tmuxClient)
+			indexed.Fallback = activeSessions
+			return listers.NewWatched(indexed, changes)
+		}
+		slog.Debug("daemon index options differ; listing directly", slog.Bool("windows", config.Windows), slog.Duration("cache_ttl", config.CacheTTL))
+	}
+	watched := listers.NewWatched(activeSessions, changes)
+	return cached(config, watched, fmt.Sprintf("active-sessions windows=%t", config.Windows))
+}
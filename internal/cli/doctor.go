@@ -0,0 +1,341 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+
+	"github.com/ian-howell/treemux/internal/daemon"
+	"github.com/ian-howell/treemux/internal/git"
+	"github.com/ian-howell/treemux/internal/state"
+	"github.com/ian-howell/treemux/internal/tmux"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// checkStatus is the outcome of a doctor check.
+type checkStatus string
+
+const (
+	statusOK   checkStatus = "ok"
+	statusWarn checkStatus = "warn"
+	statusFail checkStatus = "fail"
+)
+
+// doctorCheck is one finding of `treemux doctor`.
+type doctorCheck struct {
+	Name   string      `json:"name"`
+	Status checkStatus `json:"status"`
+	Detail string      `json:"detail"`
+
+	// Hint explains how to fix a problem.
+	Hint string `json:"hint,omitempty"`
+}
+
+// listerTiming is how one of the picker's listers fared.
+type listerTiming struct {
+	Name       string  `json:"name"`
+	Sessions   int     `json:"sessions"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// doctorReport is the output of `treemux doctor`.
+type doctorReport struct {
+	OS      string         `json:"os"`
+	Arch    string         `json:"arch"`
+	Checks  []doctorCheck  `json:"checks"`
+	Listers []listerTiming `json:"listers"`
+}
+
+// failures counts the failed checks and listers.
+func (r doctorReport) failures() int {
+	failures := 0
+	for _, check := range r.Checks {
+		if check.Status == statusFail {
+			failures++
+		}
+	}
+	for _, lister := range r.Listers {
+		if lister.Error != "" {
+			failures++
+		}
+	}
+	return failures
+}
+
+// runDoctor checks the environment treemux runs in and explains the problems it finds.
+func runDoctor(config Config, tmuxClient *tmux.Client, args []string) error {
+	flags := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "Print the report as JSON, e.g. to attach to a bug report.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("usage: treemux doctor [--json]")
+	}
+
+	report := diagnose(config, tmuxClient)
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else if err := writeReport(os.Stdout, report); err != nil {
+		return err
+	}
+	switch failures := report.failures(); {
+	case failures == 1:
+		return errors.New("1 problem found")
+	case failures > 1:
+		return fmt.Errorf("%d problems found", failures)
+	}
+	return nil
+}
+
+// diagnose runs every check, then lists the picker's listers once to time them.
+func diagnose(config Config, tmuxClient *tmux.Client) doctorReport {
+	return doctorReport{
+		OS:   runtime.GOOS,
+		Arch: runtime.GOARCH,
+		Checks: []doctorCheck{
+			checkTmux(tmuxClient),
+			checkServer(tmuxClient),
+			checkClient(tmuxClient),
+			checkDaemon(),
+			checkGit(),
+			checkConfig(config),
+			checkStateDir(),
+			checkTerminal(config),
+		},
+		Listers: timeListers(pickerListers(config, tmuxClient, nil)),
+	}
+}
+
+// writeReport prints the report as aligned columns.
+func writeReport(w io.Writer, report doctorReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, check := range report.Checks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", check.Status, check.Name, check.Detail)
+		if check.Hint != "" {
+			fmt.Fprintf(tw, "\t\thint: %s\n", check.Hint)
+		}
+	}
+	fmt.Fprintln(tw)
+	for _, lister := range report.Listers {
+		if lister.Error != "" {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", statusFail, lister.Name, lister.Error)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d sessions in %.1fms\n", statusOK, lister.Name, lister.Sessions, lister.DurationMS)
+	}
+	return tw.Flush()
+}
+
+// checkTmux checks that tmux is installed and has the features treemux uses.
+func checkTmux(tmuxClient *tmux.Client) doctorCheck {
+	check := doctorCheck{Name: "tmux"}
+	path, err := exec.LookPath("tmux")
+	if err != nil {
+		check.Status, check.Detail = statusFail, "tmux is not installed"
+		check.Hint = "install tmux and make sure it is in PATH"
+		return check
+	}
+	version, err := tmuxClient.Version()
+	if err != nil {
+		check.Status, check.Detail = statusFail, fmt.Sprintf("%s does not run: %v", path, err)
+		return check
+	}
+	check.Status, check.Detail = statusOK, fmt.Sprintf("tmux %s at %s", version, path)
+	var missing []string
+	for _, capability := range tmux.Capabilities {
+		if !version.AtLeast(capability.Major, capability.Minor) {
+			missing = append(missing, capability.String())
+		}
+	}
+	if len(missing) > 0 {
+		newest := tmux.Capabilities[len(tmux.Capabilities)-1]
+		check.Status = statusWarn
+		check.Detail += ", which lacks " + strings.Join(missing, ", ")
+		check.Hint = fmt.Sprintf("upgrade to tmux %d.%d or newer", newest.Major, newest.Minor)
+	}
+	return check
+}
+
+// checkServer checks that the tmux server can be reached on its socket.
+func checkServer(tmuxClient *tmux.Client) doctorCheck {
+	check := doctorCheck{Name: "server"}
+	output, err := tmuxClient.RunCmd([]string{"list-sessions", "-F", "#{socket_path}"})
+	switch {
+	case errors.Is(err, tmux.ErrNoServer):
+		check.Status, check.Detail = statusWarn, "no tmux server is running"
+		check.Hint = "start tmux, or run `treemux here` to create a session"
+	case err != nil:
+		check.Status, check.Detail = statusFail, err.Error()
+		check.Hint = "check that the socket's directory belongs to you, and that TMUX_TMPDIR is the same as when tmux started"
+	default:
+		lines := strings.Split(strings.TrimSpace(output), "\n")
+		check.Status, check.Detail = statusOK, fmt.Sprintf("%d sessions", len(lines))
+		if lines[0] != "" {
+			check.Detail = fmt.Sprintf("%d sessions on %s", len(lines), lines[0])
+		}
+	}
+	return check
+}
+
+// checkClient reports which tmux client treemux acts on.
+func checkClient(tmuxClient *tmux.Client) doctorCheck {
+	check := doctorCheck{Name: "client", Status: statusOK}
+	if client, ok := popupClient(); ok {
+		check.Detail = fmt.Sprintf("in a popup of client %s", client)
+		return check
+	}
+	if os.Getenv("TMUX") == "" {
+		check.Detail = "outside tmux: attaching starts a new client"
+		return check
+	}
+	client := tmuxClient.ClientTTY()
+	if client == "" {
+		check.Status, check.Detail = statusWarn, "TMUX is set, but tmux does not know the current client"
+		check.Hint = "TMUX may be left over from a shell that outlived its tmux server; unset it"
+		return check
+	}
+	check.Detail = fmt.Sprintf("inside tmux: client %s in session %s", client, tmuxClient.CurrentSession())
+	return check
+}
+
+// checkDaemon reports whether sessions come from the daemon.
+func checkDaemon() doctorCheck {
+	path := daemon.SocketPath()
+	if _, err := daemon.Dial(path); err != nil {
+		return doctorCheck{Name: "daemon", Status: statusOK, Detail: "not running: sessions are listed from tmux directly"}
+	}
+	return doctorCheck{Name: "daemon", Status: statusOK, Detail: fmt.Sprintf("running on %s", path)}
+}
+
+// checkGit checks that git is recent enough for worktree sessions.
+func checkGit() doctorCheck {
+	check := doctorCheck{Name: "git"}
+	version, err := git.Version()
+	if errors.Is(err, git.ErrGitNotInstalled) {
+		check.Status, check.Detail = statusWarn, "git is not installed: `treemux worktree` is unavailable"
+		check.Hint = fmt.Sprintf("install git %s or newer", git.MinWorktreeVersion)
+		return check
+	}
+	if err != nil {
+		check.Status, check.Detail = statusFail, err.Error()
+		return check
+	}
+	if !git.SupportsWorktrees(version) {
+		check.Status, check.Detail = statusWarn, fmt.Sprintf("git %s is too old for `treemux worktree`", version)
+		check.Hint = fmt.Sprintf("upgrade to git %s or newer", git.MinWorktreeVersion)
+		return check
+	}
+	check.Status, check.Detail = statusOK, fmt.Sprintf("git %s", version)
+	if dir, err := os.Getwd(); err == nil {
+		if root, err := git.RepositoryRoot(dir); err == nil {
+			check.Detail += fmt.Sprintf(", in repository %s", root)
+		}
+	}
+	return check
+}
+
+// checkConfig checks the configuration. treemux reads no config file; it is configured entirely
+// by flags.
+func checkConfig(config Config) doctorCheck {
+	check := doctorCheck{Name: "config"}
+	if _, err := prompterOption(config); err != nil {
+		check.Status, check.Detail = statusFail, err.Error()
+		check.Hint = "use --prompter huh, tree or exec, and give exec a --prompter-command"
+		return check
+	}
+	check.Status = statusOK
+	check.Detail = fmt.Sprintf("no config file, from flags: prompter=%s windows=%t cache-ttl=%s",
+		config.Prompter, config.Windows, config.CacheTTL)
+	return check
+}
+
+// checkStateDir checks that the state directory is writable and private.
+func checkStateDir() doctorCheck {
+	check := doctorCheck{Name: "state"}
+	dir, err := state.Dir()
+	if err != nil {
+		check.Status, check.Detail = statusFail, err.Error()
+		check.Hint = "set TREEMUX_STATE_DIR to a writable directory"
+		return check
+	}
+	probe, err := os.CreateTemp(dir, ".doctor.*")
+	if err != nil {
+		check.Status, check.Detail = statusFail, fmt.Sprintf("%s is not writable: %v", dir, err)
+		check.Hint = "fix the directory's permissions, or set TREEMUX_STATE_DIR to a writable directory"
+		return check
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	info, err := os.Stat(dir)
+	if err == nil && info.Mode().Perm()&0o077 != 0 {
+		check.Status, check.Detail = statusWarn, fmt.Sprintf("%s is accessible to other users (%s)", dir, info.Mode().Perm())
+		check.Hint = fmt.Sprintf("chmod 700 %s", dir)
+		return check
+	}
+	check.Status, check.Detail = statusOK, dir
+	return check
+}
+
+// checkTerminal checks that the prompter has a terminal: it draws on stderr and reads keys from
+// stdin, or from /dev/tty when stdin is not a terminal. An external selector brings its own.
+func checkTerminal(config Config) doctorCheck {
+	check := doctorCheck{Name: "terminal"}
+	if config.Prompter == "exec" {
+		check.Status, check.Detail = statusOK, "not needed: the exec prompter runs "+config.PrompterCommand
+		return check
+	}
+	if !term.IsTerminal(os.Stderr.Fd()) {
+		check.Status, check.Detail = statusFail, "stderr is not a terminal, so the prompter cannot be drawn"
+		check.Hint = "run treemux from a terminal, or use `treemux popup` from a tmux binding"
+		return check
+	}
+	if term.IsTerminal(os.Stdin.Fd()) {
+		check.Status, check.Detail = statusOK, "stdin and stderr are terminals"
+		return check
+	}
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		check.Status, check.Detail = statusFail, fmt.Sprintf("stdin is not a terminal and /dev/tty cannot be opened: %v", err)
+		check.Hint = "run treemux from a terminal"
+		return check
+	}
+	tty.Close()
+	check.Status, check.Detail = statusOK, "stdin is not a terminal: the prompter reads keys from /dev/tty"
+	return check
+}
+
+// timeListers lists each lister once and records how long it took. A lister finding no tmux server
+// is not failing: the server check already reports it.
+func timeListers(listers []treemux.Lister) []listerTiming {
+	timings := make([]listerTiming, 0, len(listers))
+	for _, lister := range listers {
+		start := time.Now()
+		sessions, err := lister.List()
+		timing := listerTiming{
+			Name:       treemux.ListerName(lister),
+			Sessions:   len(sessions),
+			DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if err != nil && !errors.Is(err, treemux.ErrTmuxNotRunning) {
+			timing.Error = err.Error()
+		}
+		timings = append(timings, timing)
+	}
+	return timings
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ian-howell/treemux/internal/tmuxtest"
+)
+
+func TestDiagnose(t *testing.T) {
+	server := tmuxtest.NewServer(t)
+	server.NewSession("work", t.TempDir())
+	stateDir := filepath.Join(t.TempDir(), "state")
+	t.Setenv("TREEMUX_STATE_DIR", stateDir)
+	t.Setenv("TREEMUX_SOCKET", filepath.Join(t.TempDir(), "missing.sock"))
+	tmuxClient := server.Client()
+
+	report := diagnose(Config{Prompter: "huh"}, tmuxClient)
+	checks := map[string]doctorCheck{}
+	for _, check := range report.Checks {
+		checks[check.Name] = check
+	}
+	for name, want := range map[string]string{
+		"tmux":   "tmux ",
+		"server": "1 sessions on ",
+		"daemon": "not running",
+		"config": "prompter=huh",
+		"state":  stateDir,
+	} {
+		if check := checks[name]; check.Status != statusOK || !strings.Contains(check.Detail, want) {
+			t.Errorf("expected %s to be ok with %q, got %+v", name, want, check)
+		}
+	}
+	if len(report.Listers) == 0 || report.Listers[0].Sessions != 1 || report.Listers[0].Error != "" {
+		t.Fatalf("expected the session lister to list work, got %+v", report.Listers)
+	}
+
+	// Problems come with a hint, and failures are counted.
+	if err := os.Chmod(stateDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	report = diagnose(Config{Prompter: "fzf"}, tmuxClient)
+	var out strings.Builder
+	if err := writeReport(&out, report); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"warn  state", "hint: chmod 700 " + stateDir, "fail  config", `unknown prompter "fzf"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in the report:\n%s", want, out.String())
+		}
+	}
+	if report.failures() == 0 {
+		t.Fatal("expected the unknown prompter to count as a failure")
+	}
+}
+
+func TestDiagnoseWithoutServer(t *testing.T) {
+	t.Setenv("TREEMUX_STATE_DIR", t.TempDir())
+	t.Setenv("TREEMUX_SOCKET", filepath.Join(t.TempDir(), "missing.sock"))
+	server := tmuxtest.NewServer(t)
+	server.Run("kill-server")
+	tmuxClient := server.Client()
+
+	// No server is a warning, and the session lister finding none is not a second problem.
+	report := diagnose(Config{Prompter: "huh"}, tmuxClient)
+	for _, check := range report.Checks {
+		if check.Name == "server" && check.Status != statusWarn {
+			t.Errorf("expected the server check to warn, got %+v", check)
+		}
+	}
+	for _, lister := range report.Listers {
+		if lister.Error != "" {
+			t.Errorf("expected no lister to fail, got %+v", lister)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/ian-howell/treemux/internal/git"
+	"github.com/ian-howell/treemux/internal/listers"
+	"github.com/ian-howell/treemux/internal/tmux"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// runHere attaches to the session for the project containing the current directory, creating it
+// if there is none.
+func runHere(tmuxClient *tmux.Client, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: treemux here")
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	root, name := projectSession(dir)
+	// Without a server there are no sessions yet, and creating one starts the server.
+	sessions, err := listers.NewActiveSessions(tmuxClient).List()
+	if err != nil && !errors.Is(err, treemux.ErrTmuxNotRunning) {
+		return err
+	}
+	target, exists := hereTarget(sessions, root, name)
+	if !exists {
+		if err := tmuxClient.NewSession(target, root); err != nil {
+			return fmt.Errorf("creating session: %w", err)
+		}
+	}
+	return tmuxClient.AttachOrSwitch(target)
+}
+
+// projectSession resolves dir to its project root, the root of the repository or worktree
+// containing it or else dir itself, and the session name for that project. Linked worktrees are
+// named like `treemux worktree` names them.
+func projectSession(dir string) (root, name string) {
+	root, err := git.RepositoryRoot(dir)
+	if err != nil {
+		// Any directory can have a session, whether or not git can make sense of it.
+		return dir, tmux.SessionName(filepath.Base(dir))
+	}
+	if info, err := git.Worktree(root); err == nil && info.Root != info.MainRoot && info.Branch != "" {
+		return root, tmux.SessionName(filepath.Base(info.MainRoot) + "/" + info.Branch)
+	}
+	return root, tmux.SessionName(filepath.Base(root))
+}
+
+// hereTarget picks the session for root among sessions, which are ordered most recently used
+// first. It returns the session already rooted there, whatever its name, and otherwise a free name
+// based on name, suffixed if another project's session has taken it.
+func hereTarget(sessions []treemux.Session, root, name string) (target string, exists bool) {
+	taken := map[string]bool{}
+	for _, session := range sessions {
+		if samePath(session.Path(), root) {
+			return session.Name(), true
+		}
+		taken[session.Name()] = true
+	}
+	target = name
+	for i := 2; taken[target]; i++ {
+		target = name + "-" + strconv.Itoa(i)
+	}
+	return target, false
+}
+
+// samePath reports whether a and b name the same directory, looking through symlinks.
+func samePath(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(a); err == nil {
+		a = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(b); err == nil {
+		b = resolved
+	}
+	return filepath.Clean(a) == filepath.Clean(b)
+}
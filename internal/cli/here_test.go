@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ian-howell/treemux/internal/listers"
+	"github.com/ian-howell/treemux/internal/models"
+	"github.com/ian-howell/treemux/internal/tmux"
+	"github.com/ian-howell/treemux/internal/tmuxtest"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+func TestHereTarget(t *testing.T) {
+	dir := t.TempDir()
+	project := filepath.Join(dir, "api")
+	other := filepath.Join(dir, "other", "api")
+	link := filepath.Join(dir, "link")
+	for _, path := range []string{project, other} {
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(project, link); err != nil {
+		t.Fatal(err)
+	}
+	session := func(name, path string) treemux.Session {
+		return listers.ActiveSession{Session: models.Session{Name: name, Path: path}}
+	}
+
+	tests := []struct {
+		name       string
+		sessions   []treemux.Session
+		wantTarget string
+		wantExists bool
+	}{
+		{
+			name:       "no sessions",
+			wantTarget: "api",
+		},
+		{
+			name:       "existing session",
+			sessions:   []treemux.Session{session("api", project)},
+			wantTarget: "api",
+			wantExists: true,
+		},
+		{
+			name:       "same path under another name",
+			sessions:   []treemux.Session{session("scratch", dir), session("work", project)},
+			wantTarget: "work",
+			wantExists: true,
+		},
+		{
+			name:       "same path through a symlink",
+			sessions:   []treemux.Session{session("linked", link)},
+			wantTarget: "linked",
+			wantExists: true,
+		},
+		{
+			name:       "name taken by another project",
+			sessions:   []treemux.Session{session("api", other), session("api-2", dir)},
+			wantTarget: "api-3",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target, exists := hereTarget(test.sessions, project, "api")
+			if target != test.wantTarget || exists != test.wantExists {
+				t.Fatalf("expected %q (exists %v), got %q (exists %v)", test.wantTarget, test.wantExists, target, exists)
+			}
+		})
+	}
+}
+
+func TestProjectSession(t *testing.T) {
+	plain := filepath.Join(t.TempDir(), "notes.d")
+	if err := os.Mkdir(plain, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if root, name := projectSession(plain); root != plain || name != "notes_d" {
+		t.Fatalf("expected the directory itself as notes_d, got %q as %q", root, name)
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repo := filepath.Join(t.TempDir(), "repo")
+	sub := filepath.Join(repo, "sub")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if output, err := exec.Command("git", "init", "--initial-branch=main", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, output)
+	}
+	if root, name := projectSession(sub); !samePath(root, repo) || name != "repo" {
+		t.Fatalf("expected the repository root as repo, got %q as %q", root, name)
+	}
+}
+
+func TestRunHere(t *testing.T) {
+	server := tmuxtest.NewServer(t)
+	dir := filepath.Join(t.TempDir(), "project")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	server.NewSession("main", t.TempDir())
+	client := server.AttachClient("main")
+	tmuxClient := server.Client(tmux.WithClient(client))
+
+	// The first run creates the session, and later ones reuse it.
+	for range 2 {
+		if err := runHere(tmuxClient, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got := server.ClientSession(client); got != "project" {
+			t.Fatalf("expected the client switched to project, got %q", got)
+		}
+	}
+	if sessions := server.Sessions(); !slices.Equal(sessions, []string{"main", "project"}) {
+		t.Fatalf("expected main and project, got %v", sessions)
+	}
+
+	// A session rooted at the directory under another name is reused rather than duplicated.
+	server.Run("rename-session", "-t", "=project", "renamed")
+	server.Run("switch-client", "-c", client, "-t", "=main")
+	if err := runHere(tmuxClient, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.ClientSession(client); got != "renamed" {
+		t.Fatalf("expected the client switched to renamed, got %q", got)
+	}
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/ian-howell/treemux/internal/history"
+	"github.com/ian-howell/treemux/internal/tmux"
+)
+
+// runHistory updates the jump lists. It is called from the tmux hooks installed by
+// `treemux init tmux`, so that switches made outside of treemux are recorded too.
+func runHistory(tmuxClient *tmux.Client, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: treemux history record|forget")
+	}
+	switch args[0] {
+	case "record":
+		flags := flag.NewFlagSet("history record", flag.ContinueOnError)
+		client := flags.String("client", "", "Tty of the client that switched. Defaults to the current client.")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return fmt.Errorf("usage: treemux history record [--client tty] <session>")
+		}
+		if *client == "" {
+			*client = tmuxClient.ClientTTY()
+		}
+		return history.Recorder{Client: *client}.Record(flags.Arg(0))
+	case "forget":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: treemux history forget <session>")
+		}
+		store, err := history.Load()
+		if err != nil {
+			return fmt.Errorf("loading history: %w", err)
+		}
+		store.Forget(args[1])
+		if err := store.Save(); err != nil {
+			return fmt.Errorf("saving history: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown history command %q", args[0])
+	}
+}
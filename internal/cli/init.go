@@ -0,0 +1,221 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/ian-howell/treemux/internal/tmux"
+)
+
+// Marker lines delimit the block that treemux manages in a configuration file.
+const (
+	markerBegin = "# >>> treemux >>>"
+	markerEnd   = "# <<< treemux <<<"
+)
+
+// hookIndex is the index treemux uses in tmux hook arrays, so that sourcing the snippet again
+// replaces its hooks rather than adding more, and hooks set by the user are left alone.
+const hookIndex = 73
+
+// tmuxSnippet is the tmux.conf block installed by `treemux init tmux`.
+var tmuxSnippet = template.Must(template.New("tmux").Parse(`{{.Begin}}
+# Generated by "treemux init tmux". Remove with "treemux init tmux --uninstall".
+{{- if and .PickerKey .WindowPicker}}
+bind-key {{.PickerKey}} new-window "{{.Treemux}}"
+{{- else if .PickerKey}}
+bind-key {{.PickerKey}} run-shell -b "{{.Treemux}} popup"
+{{- end}}
+{{- if .LastKey}}
+bind-key {{.LastKey}} run-shell -b "{{.Treemux}} last"
+{{- end}}
+{{- if .WorktreeKey}}
+bind-key {{.WorktreeKey}} command-prompt -p "worktree branch:" { run-shell -b "cd #{q:pane_current_path} && {{.Treemux}} worktree %%" }
+{{- end}}
+set-hook -g client-session-changed[{{.HookIndex}}] "run-shell -b \"{{.Treemux}} history record --client #{q:client_tty} #{q:session_name}\""
+set-hook -g session-closed[{{.HookIndex}}] "run-shell -b \"{{.Treemux}} history forget #{q:hook_session_name}\""
+{{.End}}
+`))
+
+// tmuxBindings configures the tmux snippet. An empty key leaves that binding out.
+type tmuxBindings struct {
+	// Treemux is the shell-quoted path of the treemux executable.
+	Treemux string
+
+	PickerKey   string
+	LastKey     string
+	WorktreeKey string
+
+	// WindowPicker opens the picker in a new window rather than a popup, for tmux without popups.
+	WindowPicker bool
+}
+
+// render returns the snippet for b.
+func (b tmuxBindings) render() (string, error) {
+	var out strings.Builder
+	err := tmuxSnippet.Execute(&out, struct {
+		tmuxBindings
+		Begin, End string
+		HookIndex  int
+	}{b, markerBegin, markerEnd, hookIndex})
+	if err != nil {
+		return "", fmt.Errorf("failed to render tmux snippet: %w", err)
+	}
+	return out.String(), nil
+}
+
+// runInit prints or installs the configuration that integrates treemux with tmux.
+func runInit(tmuxClient *tmux.Client, args []string) error {
+	if len(args) == 0 || args[0] != "tmux" {
+		return fmt.Errorf("usage: treemux init tmux [--install|--uninstall] [--file path]")
+	}
+	flags := flag.NewFlagSet("init tmux", flag.ContinueOnError)
+	install := flags.Bool("install", false, "Install the snippet into the tmux configuration file instead of printing it.")
+	uninstall := flags.Bool("uninstall", false, "Remove the snippet from the tmux configuration file.")
+	file := flags.String("file", "", "tmux configuration file. Defaults to the one tmux loads.")
+	pickerKey := flags.String("picker-key", "s", "Key opening the picker popup. Empty disables the binding.")
+	lastKey := flags.String("last-key", "L", "Key toggling to the previous session. Empty disables the binding.")
+	worktreeKey := flags.String("worktree-key", "W", "Key prompting for a worktree branch. Empty disables the binding.")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *install && *uninstall {
+		return fmt.Errorf("--install and --uninstall are mutually exclusive")
+	}
+	path := *file
+	if path == "" {
+		var err error
+		if path, err = tmuxConfPath(); err != nil {
+			return err
+		}
+	}
+
+	if *uninstall {
+		if err := removeBlock(path); err != nil {
+			return err
+		}
+		fmt.Printf("Removed treemux from %s. Bindings and hooks stay active until tmux restarts.\n", path)
+		return nil
+	}
+
+	if err := tmuxClient.Require(tmux.ConfigBlocks); err != nil {
+		return fmt.Errorf("the treemux snippet cannot be used: %w", err)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate treemux: %w", err)
+	}
+	snippet, err := tmuxBindings{
+		Treemux:      tmux.Quote(executable),
+		PickerKey:    *pickerKey,
+		LastKey:      *lastKey,
+		WorktreeKey:  *worktreeKey,
+		WindowPicker: !tmuxClient.Supports(tmux.Popup),
+	}.render()
+	if err != nil {
+		return err
+	}
+	if !*install {
+		fmt.Print(snippet)
+		return nil
+	}
+	if err := installBlock(path, snippet); err != nil {
+		return err
+	}
+	fmt.Printf("Installed treemux into %s. Reload it with: tmux source-file %s\n", path, tmux.Quote(path))
+	return nil
+}
+
+// tmuxConfPath returns the tmux configuration file: the XDG one if it exists, else ~/.tmux.conf.
+func tmuxConfPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate home directory: %w", err)
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	if path := filepath.Join(configHome, "tmux", "tmux.conf"); fileExists(path) {
+		return path, nil
+	}
+	return filepath.Join(home, ".tmux.conf"), nil
+}
+
+// fileExists reports whether path exists.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// installBlock writes block into the file at path, replacing the block installed before if there
+// is one and appending it otherwise.
+func installBlock(path, block string) error {
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	before, after, found := cutBlock(string(content))
+	if !found && before != "" && !strings.HasSuffix(before, "\n") {
+		before += "\n"
+	}
+	return writeConfig(path, before+block+after)
+}
+
+// removeBlock removes the installed block from the file at path.
+func removeBlock(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	before, after, found := cutBlock(string(content))
+	if !found {
+		return fmt.Errorf("treemux is not installed in %s", path)
+	}
+	return writeConfig(path, before+after)
+}
+
+// cutBlock splits content around the installed block, reporting whether there is one.
+func cutBlock(content string) (before, after string, found bool) {
+	start := strings.Index(content, markerBegin+"\n")
+	if start < 0 {
+		return content, "", false
+	}
+	end := strings.Index(content[start:], markerEnd)
+	if end < 0 {
+		return content, "", false
+	}
+	end += start + len(markerEnd)
+	if end < len(content) && content[end] == '\n' {
+		end++
+	}
+	return content[:start], content[end:], true
+}
+
+// writeConfig replaces the file at path, keeping its permissions. A symlinked file, as is common
+// with dotfile managers, is replaced at its target.
+func writeConfig(path, content string) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	mode := fs.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	tmp := path + ".treemux.tmp"
+	if err := os.WriteFile(tmp, []byte(content), mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
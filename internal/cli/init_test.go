@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ian-howell/treemux/internal/tmux"
+	"github.com/ian-howell/treemux/internal/tmuxtest"
+)
+
+func TestTmuxSnippetSources(t *testing.T) {
+	server := tmuxtest.NewServer(t)
+	dir := t.TempDir()
+
+	// A stub treemux records how the hooks call it.
+	calls := filepath.Join(dir, "calls")
+	stub := filepath.Join(dir, "tree mux")
+	if err := os.WriteFile(stub, []byte("#!/bin/sh\necho \"$@\" >> '"+calls+"'\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	snippet, err := tmuxBindings{Treemux: tmux.Quote(stub), PickerKey: "s", LastKey: "L", WorktreeKey: "W"}.render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := filepath.Join(dir, "tmux.conf")
+	if err := os.WriteFile(conf, []byte(snippet), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmux := server.Run
+	tmux("new-session", "-d", "-s", "main")
+
+	// Sourcing twice must not duplicate the hooks.
+	tmux("source-file", conf)
+	tmux("source-file", conf)
+	if keys := tmux("list-keys", "-T", "prefix", "s"); !strings.Contains(keys, "popup") {
+		t.Fatalf("expected the picker binding, got %q", keys)
+	}
+	hooks := tmux("show-hooks", "-g")
+	if n := strings.Count(hooks, "history record"); n != 1 {
+		t.Fatalf("expected one history hook, got %d in %q", n, hooks)
+	}
+
+	tmux("new-session", "-d", "-s", "closing")
+	tmux("kill-session", "-t", "closing")
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if data, _ := os.ReadFile(calls); strings.Contains(string(data), "history forget closing") {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			data, _ := os.ReadFile(calls)
+			t.Fatalf("expected the session-closed hook to forget the session, got %q", data)
+		}
+	}
+}
+
+func TestInstallBlock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tmux.conf")
+	original := "set -g mouse on"
+	if err := os.WriteFile(path, []byte(original), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	block := func(key string) string {
+		snippet, err := tmuxBindings{Treemux: "treemux", PickerKey: key}.render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return snippet
+	}
+
+	// Installing again replaces the block instead of adding another one.
+	for _, key := range []string{"s", "s", "T"} {
+		if err := installBlock(path, block(key)); err != nil {
+			t.Fatalf("installBlock: %v", err)
+		}
+	}
+	data, _ := os.ReadFile(path)
+	if want := original + "\n" + block("T"); string(data) != want {
+		t.Fatalf("expected\n%s\ngot\n%s", want, data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected permissions to be kept, got %v", info.Mode())
+	}
+
+	if err := removeBlock(path); err != nil {
+		t.Fatalf("removeBlock: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != original+"\n" {
+		t.Fatalf("expected the original configuration, got %q", data)
+	}
+	if err := removeBlock(path); err == nil {
+		t.Fatal("expected removing a missing block to fail")
+	}
+}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/ian-howell/treemux/internal/history"
+	"github.com/ian-howell/treemux/internal/tmux"
+)
+
+// runJump moves through the current client's jump list and switches to the resulting session.
+// Sessions that no longer exist are skipped. A dry run leaves the jump list as it was.
+func runJump(config Config, tmuxClient *tmux.Client, direction string) error {
+	store, err := history.Load()
+	if err != nil {
+		return fmt.Errorf("loading history: %w", err)
+	}
+	list := store.For(tmuxClient.ClientTTY())
+
+	// Sessions may have been switched outside of treemux, so make sure the jump list starts from
+	// where the client actually is.
+	current := tmuxClient.CurrentSession()
+	list.Push(current)
+
+	var (
+		name string
+		ok   bool
+	)
+	switch direction {
+	case "back":
+		if name, ok = list.Back(tmuxClient.HasSession); !ok {
+			return fmt.Errorf("no earlier session in history")
+		}
+	case "forward":
+		if name, ok = list.Forward(tmuxClient.HasSession); !ok {
+			return fmt.Errorf("no later session in history")
+		}
+	case "last":
+		if name, ok = list.Last(current, tmuxClient.HasSession); !ok {
+			return fmt.Errorf("no previous session in history")
+		}
+	}
+	if !config.DryRun {
+		if err := store.Save(); err != nil {
+			return fmt.Errorf("saving history: %w", err)
+		}
+	}
+	return tmuxClient.AttachOrSwitch(name)
+}
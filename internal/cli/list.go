@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ian-howell/treemux/internal/git"
+	"github.com/ian-howell/treemux/internal/listers"
+	"github.com/ian-howell/treemux/internal/tmux"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// runList prints the names that `treemux open` accepts, one per line, for shell completion.
+func runList(tmuxClient *tmux.Client, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	branches := flags.Bool("branches", false, "Also list the local branches of the repository containing the current directory.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// Without a server there are still branches to complete, so ErrTmuxNotRunning is returned
+	// only once they have been printed.
+	sessions, listErr := listers.NewActiveSessions(tmuxClient).List()
+	if listErr != nil && !errors.Is(listErr, treemux.ErrTmuxNotRunning) {
+		return listErr
+	}
+	seen := map[string]bool{}
+	for _, session := range sessions {
+		seen[session.Name()] = true
+		fmt.Println(session.Name())
+	}
+	if !*branches {
+		return listErr
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	root, err := git.RepositoryRoot(dir)
+	if err != nil {
+		// Outside of a repository there are simply no branches to complete.
+		return listErr
+	}
+	names, err := git.Branches(root)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if !seen[name] {
+			fmt.Println(name)
+		}
+	}
+	return listErr
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ian-howell/treemux/internal/tmux"
+)
+
+// pollInterval is how often sessions are first polled when tmux control mode is unavailable. The
+// interval doubles up to maxPollInterval while nothing changes, and is reset by a change.
+const (
+	pollInterval    = time.Second
+	maxPollInterval = 30 * time.Second
+)
+
+// pollFormat describes the sessions and windows the picker shows, so that polling detects the
+// changes the control-mode notifications would have reported.
+const pollFormat = "#{session_name}\t#{session_path}\t#{session_attached}\t#{window_index}\t#{window_name}"
+
+// changeNotifications are the control-mode notifications after which sessions are listed again.
+var changeNotifications = []string{
+	"sessions-changed",
+	"session-renamed",
+	"client-session-changed",
+	"window-add",
+	"window-close",
+	"window-renamed",
+	"unlinked-window-add",
+	"unlinked-window-close",
+	"unlinked-window-renamed",
+}
+
+// sessionChanges signals whenever tmux sessions or windows may have changed. It follows
+// control-mode notifications, and polls instead when control mode is unavailable. The returned
+// function stops watching and closes the channel.
+//
+// Replayed and dry runs watch nothing and close the channel at once: a replay would answer the
+// watch from the transcript in an unpredictable order, and a dry run's model only changes when
+// treemux changes it.
+func sessionChanges(config Config, tmuxClient *tmux.Client) (<-chan struct{}, func()) {
+	if config.Replay != "" || config.DryRun {
+		changes := make(chan struct{})
+		close(changes)
+		return changes, func() {}
+	}
+	var (
+		changes = make(chan struct{}, 1)
+		stop    = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+	signal := func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
+	wg.Go(func() {
+		defer close(changes)
+		control, err := tmuxClient.Control()
+		if err != nil {
+			slog.Debug("polling for session changes", slog.Any("reason", err))
+			poll(tmuxClient, pollInterval, maxPollInterval, stop, signal)
+			return
+		}
+		defer control.Close()
+		notifications := control.Subscribe(changeNotifications...)
+		for {
+			select {
+			case <-stop:
+				return
+			case _, ok := <-notifications:
+				if !ok {
+					return
+				}
+				signal()
+			}
+		}
+	})
+	return changes, func() {
+		close(stop)
+		wg.Wait()
+	}
+}
+
+// poll signals whenever the sessions and windows differ from the previous poll, until stop is
+// closed. Only a cheap query runs on each poll, and listers run again only after a change. The
+// interval between polls backs off from first to limit while nothing changes.
+func poll(tmuxClient *tmux.Client, first, limit time.Duration, stop <-chan struct{}, signal func()) {
+	last, _ := tmuxClient.RunCmd([]string{"list-windows", "-a", "-F", pollFormat})
+	interval := first
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+		current, _ := tmuxClient.RunCmd([]string{"list-windows", "-a", "-F", pollFormat})
+		if current != last {
+			last = current
+			interval = first
+			signal()
+		} else {
+			interval = min(2*interval, limit)
+		}
+		timer.Reset(interval)
+	}
+}
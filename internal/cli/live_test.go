@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ian-howell/treemux/internal/tmux"
+	"github.com/ian-howell/treemux/internal/tmuxfake"
+)
+
+func TestPoll(t *testing.T) {
+	server := &tmuxfake.Server{}
+	server.AddSession("work", "/work")
+	tmuxClient := tmux.New(tmux.WithRunner(server))
+
+	var signals atomic.Int32
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		poll(tmuxClient, time.Millisecond, 8*time.Millisecond, stop, func() { signals.Add(1) })
+	}()
+
+	// Nothing changes, so nothing is signaled and the polls back off.
+	time.Sleep(100 * time.Millisecond)
+	if n := signals.Load(); n != 0 {
+		t.Fatalf("expected no signals without changes, got %d", n)
+	}
+	polls := len(server.Calls())
+	if polls > 20 {
+		t.Fatalf("expected polling to back off, got %d polls in 100ms", polls)
+	}
+
+	server.AddSession("notes", "/notes")
+	for start := time.Now(); signals.Load() == 0; time.Sleep(time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("expected a signal after a session was added")
+		}
+	}
+	close(stop)
+	<-done
+	if n := signals.Load(); n != 1 {
+		t.Fatalf("expected one signal for one change, got %d", n)
+	}
+}
@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ian-howell/treemux/internal/git"
+	"github.com/ian-howell/treemux/internal/tmux"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// runOpen attaches to the named session or, inside a repository, to a worktree for the named
+// branch. Without a name it behaves like `treemux here`.
+func runOpen(config Config, tmuxClient *tmux.Client, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: treemux open [session|branch]")
+	}
+	if len(args) == 0 {
+		return runHere(tmuxClient, nil)
+	}
+	name := args[0]
+	if tmuxClient.HasSession(name) {
+		return tmuxClient.AttachOrSwitch(name)
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if _, err := git.RepositoryRoot(dir); err != nil {
+		return &treemux.SessionNotFoundError{Name: name}
+	}
+	return runWorktree(config, tmuxClient, []string{name})
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+
+	"github.com/ian-howell/treemux/internal/tmux"
+)
+
+// popupEnv holds the tty of the client a popup was opened for. It is set by `treemux popup` for
+// the picker it starts in the popup.
+const popupEnv = "TREEMUX_POPUP"
+
+// popupClient reports whether treemux runs in a tmux popup, and returns the tty of the client the
+// popup belongs to. Besides popups opened by `treemux popup`, it recognizes `display-popup -E
+// treemux`: popups have a terminal and TMUX set, but unlike panes no TMUX_PANE.
+func popupClient() (string, bool) {
+	if tty := os.Getenv(popupEnv); tty != "" {
+		return tty, true
+	}
+	if os.Getenv("TMUX") == "" || os.Getenv("TMUX_PANE") != "" || !term.IsTerminal(os.Stdin.Fd()) {
+		return "", false
+	}
+	return tmux.New().ClientTTY(), true
+}
+
+// runPopup opens the picker in a popup on the current client. Inside a popup it runs the picker
+// directly.
+func runPopup(config Config, tmuxClient *tmux.Client, args []string) error {
+	flags := flag.NewFlagSet("popup", flag.ContinueOnError)
+	width := flags.String("width", "80%", "Popup width, in cells or as a percentage of the client.")
+	height := flags.String("height", "60%", "Popup height, in cells or as a percentage of the client.")
+	border := flags.String("border", "", "Popup border lines, e.g. single, rounded, double or none. Empty uses the tmux default.")
+	title := flags.String("title", "", "Popup title.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if config.DryRun {
+		// The popup's output vanishes when it closes, and with it the commands a dry run prints.
+		return fmt.Errorf("--dry-run cannot be shown in a popup; run treemux --dry-run in a pane instead")
+	}
+
+	if _, ok := popupClient(); ok {
+		return runPicker(config, tmuxClient)
+	}
+	client := tmuxClient.ClientTTY()
+	if client == "" {
+		return fmt.Errorf("treemux popup must be run inside tmux")
+	}
+	if err := tmuxClient.Require(tmux.Popup); err != nil {
+		return fmt.Errorf("%w; open the picker in a window instead, e.g. bind-key s new-window treemux", err)
+	}
+	if (*border != "" || *title != "") && !tmuxClient.Supports(tmux.PopupStyle) {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring --border and --title: %v\n", tmuxClient.Require(tmux.PopupStyle))
+		*border, *title = "", ""
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate treemux: %w", err)
+	}
+
+	popup := []string{"display-popup", "-E", "-c", client, "-w", *width, "-h", *height}
+	if *border != "" {
+		popup = append(popup, "-b", *border)
+	}
+	if *title != "" {
+		popup = append(popup, "-T", *title)
+	}
+	command := []string{popupEnv + "=" + tmux.Quote(client), "exec", tmux.Quote(executable)}
+	for _, arg := range pickerArgs(config) {
+		command = append(command, tmux.Quote(arg))
+	}
+	popup = append(popup, strings.Join(command, " "))
+	if _, err := tmuxClient.RunCmd(popup); err != nil {
+		return fmt.Errorf("failed to open popup: %w", err)
+	}
+	return nil
+}
+
+// pickerArgs returns the global flags that reproduce config for a picker started in a popup.
+func pickerArgs(config Config) []string {
+	args := []string{"--prompter", config.Prompter}
+	if config.PrompterCommand != "" {
+		args = append(args, "--prompter-command", config.PrompterCommand)
+	}
+	if config.PrompterIndex {
+		args = append(args, "--prompter-index")
+	}
+	if config.Windows {
+		args = append(args, "--windows")
+	}
+	if config.CacheTTL > 0 {
+		args = append(args, "--cache-ttl", config.CacheTTL.String())
+	}
+	for _, command := range config.ExecListers {
+		args = append(args, "--exec-lister", command)
+	}
+	if config.ExecTimeout > 0 {
+		args = append(args, "--exec-timeout", config.ExecTimeout.String())
+	}
+	if config.RestoreCommands {
+		args = append(args, "--restore-commands")
+	}
+	if config.Debug {
+		args = append(args, "--debug")
+	}
+	// The popup starts in the directory of the client's pane, so transcripts are passed by
+	// absolute path.
+	if config.Record != "" {
+		args = append(args, "--record", absPath(config.Record))
+	}
+	if config.Replay != "" {
+		args = append(args, "--replay", absPath(config.Replay))
+	}
+	return args
+}
+
+// absPath returns the absolute form of path, or path itself if it cannot be made absolute.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+)
+
+func TestPickerArgs(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	tests := []struct {
+		name   string
+		config Config
+		want   []string
+	}{
+		{name: "defaults", config: Config{Prompter: "huh"}, want: []string{"--prompter", "huh"}},
+		{
+			name: "lister and prompter flags",
+			config: Config{
+				Prompter:        "exec",
+				PrompterCommand: "fzf",
+				PrompterIndex:   true,
+				Windows:         true,
+				CacheTTL:        time.Minute,
+				ExecListers:     []string{"jira", "k8s"},
+				ExecTimeout:     time.Second,
+				RestoreCommands: true,
+				Debug:           true,
+			},
+			want: []string{
+				"--prompter", "exec", "--prompter-command", "fzf", "--prompter-index", "--windows",
+				"--cache-ttl", "1m0s", "--exec-lister", "jira", "--exec-lister", "k8s", "--exec-timeout", "1s",
+				"--restore-commands", "--debug",
+			},
+		},
+		{
+			name:   "record by absolute path",
+			config: Config{Prompter: "huh", Record: "treemux.jsonl"},
+			want:   []string{"--prompter", "huh", "--record", filepath.Join(dir, "treemux.jsonl")},
+		},
+		{
+			name:   "replay",
+			config: Config{Prompter: "huh", Replay: "/tmp/treemux.jsonl"},
+			want:   []string{"--prompter", "huh", "--replay", "/tmp/treemux.jsonl"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := pickerArgs(test.config); !slices.Equal(got, test.want) {
+				t.Fatalf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestPopupClient(t *testing.T) {
+	tests := []struct {
+		name       string
+		popup      string
+		tmux       string
+		pane       string
+		wantClient string
+		wantOK     bool
+	}{
+		{name: "opened by treemux popup", popup: "/dev/pts/3", tmux: "/tmp/tmux-1000/default,1,0", wantClient: "/dev/pts/3", wantOK: true},
+		{name: "outside tmux", wantOK: false},
+		{name: "in a pane", tmux: "/tmp/tmux-1000/default,1,0", pane: "%1", wantOK: false},
+		// A popup always has a terminal.
+		{name: "without a terminal", tmux: "/tmp/tmux-1000/default,1,0", wantOK: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.popup == "" && test.tmux != "" && test.pane == "" && term.IsTerminal(os.Stdin.Fd()) {
+				t.Skip("stdin is a terminal")
+			}
+			t.Setenv(popupEnv, test.popup)
+			t.Setenv("TMUX", test.tmux)
+			t.Setenv("TMUX_PANE", test.pane)
+			client, ok := popupClient()
+			if client != test.wantClient || ok != test.wantOK {
+				t.Fatalf("expected %q, %t, got %q, %t", test.wantClient, test.wantOK, client, ok)
+			}
+		})
+	}
+}
+
+func TestPopupRefusesDryRun(t *testing.T) {
+	if err := runPopup(Config{DryRun: true}, nil, nil); err == nil {
+		t.Fatal("expected --dry-run to be refused")
+	}
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/ian-howell/treemux/internal/tmux"
+)
+
+// shellSnippets are the snippets printed by `treemux shell-init`, keyed by shell. Each defines:
+//
+//   - tm, which attaches to a session or a branch's worktree, or to the current directory's session,
+//   - completion of tm from `treemux list --branches`,
+//   - a Ctrl-key widget opening the picker, in interactive shells only.
+var shellSnippets = map[string]*template.Template{
+	"bash": template.Must(template.New("bash").Parse(`# Generated by "treemux shell-init bash". Load it with: eval "$(treemux shell-init bash)"
+tm() {
+	{{.Treemux}} open "$@"
+}
+
+__treemux_tm_complete() {
+	local IFS=$'\n'
+	COMPREPLY=($(compgen -W "$({{.Treemux}} list --branches 2>/dev/null)" -- "${COMP_WORDS[COMP_CWORD]}"))
+}
+complete -F __treemux_tm_complete tm
+
+__treemux_widget() {
+	{{.Treemux}} </dev/tty
+}
+if [[ $- == *i* ]]; then
+	bind -x '"\C-{{.Key}}": __treemux_widget'
+fi
+`)),
+	"zsh": template.Must(template.New("zsh").Parse(`# Generated by "treemux shell-init zsh". Load it after compinit with: eval "$(treemux shell-init zsh)"
+tm() {
+	{{.Treemux}} open "$@"
+}
+
+__treemux_tm_complete() {
+	local -a names
+	names=(${(f)"$({{.Treemux}} list --branches 2>/dev/null)"})
+	compadd -a names
+}
+if (( $+functions[compdef] )); then
+	compdef __treemux_tm_complete tm
+fi
+
+__treemux_widget() {
+	zle -I
+	{{.Treemux}} </dev/tty
+	zle reset-prompt
+}
+if [[ -o interactive ]]; then
+	zle -N __treemux_widget
+	bindkey '^{{.Key}}' __treemux_widget
+fi
+`)),
+	"fish": template.Must(template.New("fish").Parse(`# Generated by "treemux shell-init fish". Load it with: treemux shell-init fish | source
+function tm --description 'Attach to a treemux session or branch'
+	{{.Treemux}} open $argv
+end
+
+complete -c tm -f -a "({{.Treemux}} list --branches 2>/dev/null)"
+
+function __treemux_widget
+	{{.Treemux}} </dev/tty
+	commandline -f repaint
+end
+if status is-interactive
+	bind \c{{.Key}} __treemux_widget
+end
+`)),
+}
+
+// shellBindings configures a shell snippet.
+type shellBindings struct {
+	// Treemux is the shell-quoted path of the treemux executable.
+	Treemux string
+
+	// Key is the letter that, with Ctrl, opens the picker.
+	Key string
+}
+
+// render returns the snippet for shell.
+func (b shellBindings) render(shell string) (string, error) {
+	snippet, ok := shellSnippets[shell]
+	if !ok {
+		return "", fmt.Errorf("unsupported shell %q: want bash, zsh or fish", shell)
+	}
+	if len(b.Key) != 1 || b.Key[0] < 'a' || b.Key[0] > 'z' {
+		return "", fmt.Errorf("invalid key %q: want a single lowercase letter", b.Key)
+	}
+	var out strings.Builder
+	if err := snippet.Execute(&out, b); err != nil {
+		return "", fmt.Errorf("failed to render %s snippet: %w", shell, err)
+	}
+	return out.String(), nil
+}
+
+// runShellInit prints the snippet integrating treemux with the given shell.
+func runShellInit(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: treemux shell-init bash|zsh|fish [--key letter]")
+	}
+	flags := flag.NewFlagSet("shell-init", flag.ContinueOnError)
+	key := flags.String("key", "g", "Letter that, with Ctrl, opens the picker.")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate treemux: %w", err)
+	}
+	snippet, err := shellBindings{Treemux: tmux.Quote(executable), Key: *key}.render(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Print(snippet)
+	return nil
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ian-howell/treemux/internal/tmux"
+)
+
+func TestShellSnippetsSource(t *testing.T) {
+	tests := []struct {
+		shell string
+		// script loads the snippet at $SNIPPET, calls tm and completes "tm al".
+		script string
+	}{
+		{
+			shell: "bash",
+			script: `eval "$(cat "$SNIPPET")"
+tm feature
+COMP_WORDS=(tm al); COMP_CWORD=1
+__treemux_tm_complete
+printf '%s\n' "${COMPREPLY[@]}"`,
+		},
+		{
+			shell: "zsh",
+			script: `eval "$(cat "$SNIPPET")"
+tm feature
+# Outside of completion, stand in for compadd to print the candidates.
+compadd() { print -l ${(P)2} }
+__treemux_tm_complete`,
+		},
+		{
+			shell: "fish",
+			script: `source $SNIPPET
+tm feature
+complete -C "tm al"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.shell, func(t *testing.T) {
+			if _, err := exec.LookPath(test.shell); err != nil {
+				t.Skipf("%s is not installed", test.shell)
+			}
+			dir := t.TempDir()
+			calls := filepath.Join(dir, "calls")
+			stub := filepath.Join(dir, "tree mux")
+			script := "#!/bin/sh\nif [ \"$1\" = list ]; then printf 'alpha\\nbeta\\n'; exit; fi\necho \"$@\" >> '" + calls + "'\n"
+			if err := os.WriteFile(stub, []byte(script), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			snippet, err := shellBindings{Treemux: tmux.Quote(stub), Key: "g"}.render(test.shell)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, "snippet")
+			if err := os.WriteFile(path, []byte(snippet), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			cmd := exec.Command(test.shell, "-c", test.script)
+			cmd.Env = append(os.Environ(), "SNIPPET="+path)
+			var stderr strings.Builder
+			cmd.Stderr = &stderr
+			output, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("%v: %s", err, stderr.String())
+			}
+			// Sourcing in a non-interactive shell must not complain about key bindings.
+			if stderr.Len() > 0 {
+				t.Fatalf("unexpected stderr: %s", stderr.String())
+			}
+			if got := strings.Fields(string(output)); len(got) == 0 || got[0] != "alpha" {
+				t.Fatalf("expected completion alpha, got %q", output)
+			}
+			logged, err := os.ReadFile(calls)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(string(logged)); got != "open feature" {
+				t.Fatalf("expected tm to run \"open feature\", got %q", got)
+			}
+		})
+	}
+}
+
+func TestShellBindingsRender(t *testing.T) {
+	if _, err := (shellBindings{Treemux: "treemux", Key: "g"}).render("tcsh"); err == nil {
+		t.Fatal("expected an error for an unsupported shell")
+	}
+	if _, err := (shellBindings{Treemux: "treemux", Key: "G"}).render("bash"); err == nil {
+		t.Fatal("expected an error for an invalid key")
+	}
+	snippet, err := shellBindings{Treemux: "treemux", Key: "t"}.render("bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(snippet, `"\C-t"`) {
+		t.Fatalf("expected a Ctrl-T binding, got %q", snippet)
+	}
+}
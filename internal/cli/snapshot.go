@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+
+	"github.com/ian-howell/treemux/internal/snapshot"
+	"github.com/ian-howell/treemux/internal/tmux"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// runSave snapshots every running session.
+func runSave(tmuxClient *tmux.Client, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: treemux save")
+	}
+	saved, err := snapshot.Capture(tmuxClient)
+	if err != nil {
+		return fmt.Errorf("capturing sessions: %w", err)
+	}
+	if err := saved.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("Saved %d sessions.\n", len(saved.Sessions))
+	return nil
+}
+
+// runRestore rebuilds the saved sessions that are not running, or only the named ones.
+func runRestore(tmuxClient *tmux.Client, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	noCommands := flags.Bool("no-commands", false, "Do not rerun the commands that were running in each pane.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	saved, err := snapshot.Load()
+	if err != nil {
+		return err
+	}
+	names := flags.Args()
+	for _, name := range names {
+		if _, ok := saved.Session(name); !ok {
+			return fmt.Errorf("%w among the saved sessions", &treemux.SessionNotFoundError{Name: name})
+		}
+	}
+	options := snapshot.RestoreOptions{Commands: !*noCommands}
+	for _, session := range saved.Sessions {
+		if len(names) > 0 && !slices.Contains(names, session.Name) {
+			continue
+		}
+		if tmuxClient.HasSession(session.Name) {
+			fmt.Printf("Skipped %s, which is running.\n", session.Name)
+			continue
+		}
+		if err := snapshot.Restore(tmuxClient, session, options); err != nil {
+			return err
+		}
+		fmt.Printf("Restored %s.\n", session.Name)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/ian-howell/treemux/internal/listers"
+	"github.com/ian-howell/treemux/internal/tmux"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// stdinListers reads the candidates piped to treemux, then points stdin at the terminal for the
+// huh and tree prompters, which read keys from it. Other prompters leave stdin alone, so that e.g.
+// an exec selector started from a desktop launcher works without a terminal.
+func stdinListers(config Config, tmuxClient *tmux.Client) ([]treemux.Lister, error) {
+	stdin, err := listers.NewStdin(tmuxClient, os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	if terminalPrompter(config) {
+		if err := reopenTTY(); err != nil {
+			return nil, err
+		}
+	}
+	return []treemux.Lister{stdin}, nil
+}
+
+// terminalPrompter reports whether the configured prompter reads keys from the terminal.
+func terminalPrompter(config Config) bool {
+	switch config.Prompter {
+	case "", "huh", "tree":
+		return true
+	default:
+		return false
+	}
+}
+
+// reopenTTY replaces stdin, once it has been read, with the controlling terminal. The prompter
+// reads keys from it, and attaching hands it to tmux, which needs a terminal.
+func reopenTTY() error {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("--from-stdin needs a terminal for the prompter: %w", err)
+	}
+	defer tty.Close()
+	if err := unix.Dup2(int(tty.Fd()), int(os.Stdin.Fd())); err != nil {
+		return fmt.Errorf("failed to reopen the terminal: %w", err)
+	}
+	return nil
+}
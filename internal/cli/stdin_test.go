@@ -0,0 +1,17 @@
+package cli
+
+import "testing"
+
+func TestTerminalPrompter(t *testing.T) {
+	for prompter, want := range map[string]bool{
+		"":     true,
+		"huh":  true,
+		"tree": true,
+		// dmenu and rofi open windows of their own, and fzf reads keys from /dev/tty itself.
+		"exec": false,
+	} {
+		if got := terminalPrompter(Config{Prompter: prompter}); got != want {
+			t.Errorf("prompter %q: expected %t, got %t", prompter, want, got)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/ian-howell/treemux/internal/executor"
+)
+
+// logEnv names a file that debug logs are appended to. Unlike --debug, which logs to stderr, it
+// keeps the logs out of the prompter's way and works when treemux is started by tmux.
+const logEnv = "TREEMUX_LOG"
+
+// setupTracing configures debug logging and command recording or replay. The returned function
+// releases the files it opened.
+func setupTracing(config Config) (func(), error) {
+	var closers []io.Closer
+	cleanup := func() {
+		for _, closer := range closers {
+			closer.Close()
+		}
+	}
+
+	var logOutput io.Writer
+	if path := os.Getenv(logEnv); path != "" {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return cleanup, fmt.Errorf("failed to open %s: %w", logEnv, err)
+		}
+		closers = append(closers, file)
+		logOutput = file
+	} else if config.Debug {
+		logOutput = os.Stderr
+	}
+	if logOutput != nil {
+		slog.SetDefault(slog.New(slog.NewTextHandler(logOutput, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	}
+
+	if config.Record != "" && config.Replay != "" {
+		return cleanup, fmt.Errorf("--record and --replay are mutually exclusive")
+	}
+	var options []executor.Option
+	if config.Record != "" {
+		// A picker in a popup adds to the transcript of the treemux that opened the popup.
+		flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		if os.Getenv(popupEnv) == "" {
+			flags |= os.O_TRUNC
+		}
+		file, err := os.OpenFile(config.Record, flags, 0o666)
+		if err != nil {
+			return cleanup, fmt.Errorf("failed to create transcript: %w", err)
+		}
+		closers = append(closers, file)
+		options = append(options, executor.WithRecord(file))
+	}
+	if config.Replay != "" {
+		file, err := os.Open(config.Replay)
+		if err != nil {
+			return cleanup, fmt.Errorf("failed to open transcript: %w", err)
+		}
+		entries, err := executor.ReadTranscript(file)
+		file.Close()
+		if err != nil {
+			return cleanup, err
+		}
+		options = append(options, executor.WithReplay(entries))
+	}
+	if len(options) > 0 {
+		executor.SetDefault(executor.New(options...))
+	}
+	return cleanup, nil
+}
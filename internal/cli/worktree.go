@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ian-howell/treemux/internal/git"
+	"github.com/ian-howell/treemux/internal/tmux"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// bootstrapWindow is the name of the tmux window running worktree bootstrap commands.
+const bootstrapWindow = "bootstrap"
+
+// runWorktree ensures a worktree for a branch, prepares it, and attaches to a session rooted in it.
+//
+// Setup failures are reported as warnings: the worktree is kept and the session still opens.
+func runWorktree(config Config, tmuxClient *tmux.Client, args []string) error {
+	defaults := git.DefaultFetchOptions()
+	flags := flag.NewFlagSet("worktree", flag.ContinueOnError)
+	fetchPolicy := flags.String("fetch", string(defaults.Policy), "When to fetch a missing branch: never, if-stale or always.")
+	fetchMaxAge := flags.Duration("fetch-max-age", defaults.MaxAge, "How long a fetch stays fresh under the if-stale policy.")
+	fetchTimeout := flags.Duration("fetch-timeout", defaults.Timeout, "Maximum time to wait for a fetch.")
+	remote := flags.String("remote", "", "Remote to fetch missing branches from.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: treemux worktree [flags] <branch>")
+	}
+	policy, err := git.ParseFetchPolicy(*fetchPolicy)
+	if err != nil {
+		return err
+	}
+	branch := flags.Arg(0)
+
+	var worktree treemux.Worktree
+	if config.DryRun {
+		// A dry run neither fetches nor touches the repository; it prints what it would create.
+		worktree, err = treemux.PlanWorktree(branch)
+		if err == nil && worktree.Created {
+			fmt.Printf("git worktree add %s %s\n", tmux.Quote(worktree.Path), tmux.Quote(branch))
+		}
+	} else {
+		worktree, err = treemux.ResolveWorktree(branch, git.FetchOptions{
+			Policy:  policy,
+			MaxAge:  *fetchMaxAge,
+			Timeout: *fetchTimeout,
+			Remote:  *remote,
+			Warn:    warn,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("resolving worktree: %w", err)
+	}
+
+	name := tmux.SessionName(filepath.Base(worktree.MainRoot) + "/" + branch)
+	if tmuxClient.HasSession(name) {
+		return tmuxClient.AttachOrSwitch(name)
+	}
+
+	setup := treemux.WorktreeSetup{}
+	if worktree.Created {
+		setup, err = treemux.LoadWorktreeSetup(worktree.MainRoot)
+		if err != nil {
+			warn(err)
+		}
+	}
+	if worktree.Created && !config.DryRun {
+		if err := setup.LinkFiles(worktree.MainRoot, worktree.Path); err != nil {
+			warn(err)
+		}
+		if !setup.BootstrapWindow {
+			if err := setup.RunBootstrap(worktree.Path, os.Stderr, os.Stderr); err != nil {
+				warn(err)
+			}
+		}
+	}
+
+	if err := tmuxClient.NewSession(name, worktree.Path); err != nil {
+		return fmt.Errorf("creating session: %w", err)
+	}
+	if setup.BootstrapWindow && len(setup.Bootstrap) > 0 {
+		if err := startBootstrapWindow(tmuxClient, name, worktree.Path, setup.Bootstrap); err != nil {
+			warn(fmt.Errorf("starting bootstrap window: %w", err))
+		}
+	}
+	return tmuxClient.AttachOrSwitch(name)
+}
+
+// startBootstrapWindow runs the bootstrap commands one after another in a dedicated window, so
+// their output and any failure stay visible in the session.
+func startBootstrapWindow(tmuxClient *tmux.Client, session, dir string, commands []string) error {
+	if err := tmuxClient.NewWindow(session, bootstrapWindow, dir); err != nil {
+		return err
+	}
+	return tmuxClient.SendKeys(session, bootstrapWindow, strings.Join(commands, " && "))
+}
+
+// warn reports a non-fatal error.
+func warn(err error) {
+	fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+}
@@ -0,0 +1,98 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// dialTimeout bounds connecting to the daemon, so that a dead socket falls back quickly.
+const dialTimeout = 100 * time.Millisecond
+
+// callTimeout bounds a single request.
+const callTimeout = 2 * time.Second
+
+// errServer wraps errors reported by the server.
+var errServer = errors.New("daemon error")
+
+// Client talks to a running daemon.
+type Client struct {
+	path string
+
+	// options are the options of the daemon's index.
+	options IndexOptions
+}
+
+// Dial returns a client for the daemon listening at path, after checking that it is alive and
+// speaks the same protocol version.
+func Dial(path string) (*Client, error) {
+	c := &Client{path: path}
+	response, err := c.call(Request{Method: MethodPing})
+	if err != nil {
+		return nil, err
+	}
+	if response.Options != nil {
+		c.options = *response.Options
+	}
+	return c, nil
+}
+
+// Options returns the options the daemon's index is built with.
+func (c *Client) Options() IndexOptions {
+	return c.options
+}
+
+// List returns the daemon's session index.
+func (c *Client) List() ([]Session, error) {
+	response, err := c.call(Request{Method: MethodList})
+	if err != nil {
+		return nil, err
+	}
+	return response.Sessions, nil
+}
+
+// Attach asks the daemon to switch the tmux client with the given tty to session.
+func (c *Client) Attach(session, client string) error {
+	_, err := c.call(Request{Method: MethodAttach, Session: session, Client: client})
+	return err
+}
+
+// Preview returns the visible contents of the session's active pane.
+func (c *Client) Preview(session string) (string, error) {
+	response, err := c.call(Request{Method: MethodPreview, Session: session})
+	if err != nil {
+		return "", err
+	}
+	return response.Preview, nil
+}
+
+// call sends a single request on a fresh connection and waits for its response.
+func (c *Client) call(request Request) (Response, error) {
+	request.Version = ProtocolVersion
+	conn, err := net.DialTimeout("unix", c.path, dialTimeout)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to connect to daemon: %w", err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(callTimeout)); err != nil {
+		return Response{}, err
+	}
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		return Response{}, fmt.Errorf("failed to send %s request: %w", request.Method, err)
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to read %s response: %w", request.Method, err)
+	}
+	var response Response
+	if err := json.Unmarshal(line, &response); err != nil {
+		return Response{}, fmt.Errorf("invalid %s response: %w", request.Method, err)
+	}
+	if response.Error != "" {
+		return Response{}, fmt.Errorf("%w: %s", errServer, response.Error)
+	}
+	return response, nil
+}
@@ -0,0 +1,89 @@
+// Package daemon implements the optional treemux daemon, which keeps a warm session index and
+// serves it over a Unix socket so that the CLI does not have to spawn tmux and git on every run.
+//
+// The protocol is newline-delimited JSON: the client writes one Request per line and the server
+// answers each with one Response line.
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ProtocolVersion is the version of the request/response protocol. Servers reject requests
+// carrying a different version, and clients then fall back to listing directly.
+const ProtocolVersion = 1
+
+// Methods understood by the server.
+const (
+	// MethodPing checks that the server is alive and speaks the same protocol version, and returns
+	// its IndexOptions.
+	MethodPing = "ping"
+
+	// MethodList returns the indexed sessions.
+	MethodList = "list"
+
+	// MethodAttach switches a tmux client to a session.
+	MethodAttach = "attach"
+
+	// MethodPreview returns the visible contents of a session's active pane.
+	MethodPreview = "preview"
+)
+
+// Request is a single call to the daemon.
+type Request struct {
+	Version int    `json:"version"`
+	Method  string `json:"method"`
+
+	// Session is the target session of attach and preview requests.
+	Session string `json:"session,omitempty"`
+
+	// Client is the tty of the tmux client to switch in attach requests.
+	Client string `json:"client,omitempty"`
+}
+
+// Response answers a Request.
+type Response struct {
+	// Error is set when the request failed.
+	Error string `json:"error,omitempty"`
+
+	// Sessions is the index returned by list requests.
+	Sessions []Session `json:"sessions,omitempty"`
+
+	// Preview is the pane contents returned by preview requests.
+	Preview string `json:"preview,omitempty"`
+
+	// Options describes the index, answering ping requests.
+	Options *IndexOptions `json:"options,omitempty"`
+}
+
+// IndexOptions are the lister options the index was built with. A client whose own options differ
+// lists sessions itself rather than showing a different list.
+type IndexOptions struct {
+	// Windows is whether each session's windows are indexed beneath it.
+	Windows bool `json:"windows"`
+}
+
+// Session is an indexed session as sent over the wire.
+type Session struct {
+	Name  string   `json:"name"`
+	Path  string   `json:"path,omitempty"`
+	Group []string `json:"group,omitempty"`
+	Label string   `json:"label"`
+
+	// Owner names the tmux session of a nested session, such as a window.
+	Owner string `json:"owner,omitempty"`
+}
+
+// SocketPath returns the daemon socket path: $TREEMUX_SOCKET, else treemux.sock in
+// $XDG_RUNTIME_DIR, else a per-user socket in the temporary directory.
+func SocketPath() string {
+	if path := os.Getenv("TREEMUX_SOCKET"); path != "" {
+		return path
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "treemux.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("treemux-%d.sock", os.Getuid()))
+}
@@ -0,0 +1,208 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// tmuxClient is the subset of tmux operations the server performs on behalf of clients.
+type tmuxClient interface {
+	SwitchClient(client, target string) error
+	CapturePane(target string) (string, error)
+}
+
+// Server keeps a session index fresh and serves it to clients.
+type Server struct {
+	lister     treemux.Lister
+	tmuxClient tmuxClient
+
+	// interval is how often the index is rebuilt.
+	interval time.Duration
+
+	// Options describes how lister builds the index, for clients to compare with their own.
+	Options IndexOptions
+
+	// Changes, if set, signals that sessions changed and the index should be rebuilt right away
+	// rather than at the next interval.
+	Changes <-chan struct{}
+
+	mu       sync.RWMutex
+	sessions []Session
+
+	// groups caches session groups between rebuilds, since resolving them runs git.
+	groups map[string]cachedGroup
+}
+
+// groupTTL is how long a resolved group is reused before the session's repository is rescanned.
+const groupTTL = time.Minute
+
+// cachedGroup is a resolved session group.
+type cachedGroup struct {
+	group    []string
+	resolved time.Time
+}
+
+// NewServer returns a server indexing the sessions of lister every interval.
+func NewServer(lister treemux.Lister, tmuxClient tmuxClient, interval time.Duration) *Server {
+	return &Server{
+		lister:     lister,
+		tmuxClient: tmuxClient,
+		interval:   interval,
+		groups:     map[string]cachedGroup{},
+	}
+}
+
+// ListenAndServe listens on the Unix socket at path and serves requests until ctx is done. A
+// stale socket left behind by a previous server is replaced, but a live one is an error.
+func (s *Server) ListenAndServe(ctx context.Context, path string) error {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("a daemon is already listening on %s", path)
+	}
+	_ = os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	defer os.Remove(path)
+	return s.Serve(ctx, listener)
+}
+
+// Serve accepts connections on listener until ctx is done.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	s.refresh()
+	go s.refreshLoop(ctx)
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+		go s.handle(conn)
+	}
+}
+
+// refreshLoop rebuilds the index every interval and on every change until ctx is done.
+func (s *Server) refreshLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	changes := s.Changes
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.refresh()
+		case _, ok := <-changes:
+			if !ok {
+				changes = nil
+				continue
+			}
+			s.refresh()
+		}
+	}
+}
+
+// refresh lists sessions and resolves their groups so that clients never wait on git. The
+// previous index is kept if listing fails.
+func (s *Server) refresh() {
+	sessions, err := s.lister.List()
+	if err != nil {
+		return
+	}
+	// Groups are resolved without holding the lock so that clients are served during a rescan.
+	s.mu.RLock()
+	previous := s.groups
+	s.mu.RUnlock()
+
+	index := make([]Session, 0, len(sessions))
+	groups := make(map[string]cachedGroup, len(sessions))
+	for _, session := range sessions {
+		key := session.Name() + "\x00" + session.Path()
+		group, ok := previous[key]
+		if !ok || time.Since(group.resolved) > groupTTL {
+			group = cachedGroup{group: session.Group(), resolved: time.Now()}
+		}
+		groups[key] = group
+		indexed := Session{
+			Name:  session.Name(),
+			Path:  session.Path(),
+			Group: group.group,
+			Label: session.String(),
+		}
+		if nested, ok := session.(treemux.Nested); ok {
+			indexed.Owner = nested.Owner()
+		}
+		index = append(index, indexed)
+	}
+	s.mu.Lock()
+	s.sessions = index
+	s.groups = groups
+	s.mu.Unlock()
+}
+
+// handle answers requests on conn until the client disconnects.
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var request Request
+		var response Response
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			response.Error = fmt.Sprintf("invalid request: %v", err)
+		} else {
+			response = s.serve(request)
+		}
+		if err := encoder.Encode(response); err != nil {
+			return
+		}
+	}
+}
+
+// serve answers a single request.
+func (s *Server) serve(request Request) Response {
+	if request.Version != ProtocolVersion {
+		return Response{Error: fmt.Sprintf("unsupported protocol version %d (want %d)", request.Version, ProtocolVersion)}
+	}
+	switch request.Method {
+	case MethodPing:
+		return Response{Options: &s.Options}
+	case MethodList:
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return Response{Sessions: s.sessions}
+	case MethodAttach:
+		if request.Client == "" {
+			return Response{Error: "attach requires a client"}
+		}
+		if err := s.tmuxClient.SwitchClient(request.Client, request.Session); err != nil {
+			return Response{Error: err.Error()}
+		}
+		// The attached state of sessions just changed.
+		go s.refresh()
+		return Response{}
+	case MethodPreview:
+		preview, err := s.tmuxClient.CapturePane(request.Session)
+		if err != nil {
+			return Response{Error: err.Error()}
+		}
+		return Response{Preview: preview}
+	default:
+		return Response{Error: fmt.Sprintf("unknown method %q", request.Method)}
+	}
+}
@@ -0,0 +1,142 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// stubLister lists a fixed set of sessions.
+type stubLister []treemux.Session
+
+func (l stubLister) List() ([]treemux.Session, error) { return l, nil }
+
+// stubSession is a Session with fixed fields.
+type stubSession struct {
+	name, path string
+	group      []string
+}
+
+func (s stubSession) Attach() error   { return nil }
+func (s stubSession) String() string  { return "  " + s.name }
+func (s stubSession) Name() string    { return s.name }
+func (s stubSession) Path() string    { return s.path }
+func (s stubSession) Group() []string { return s.group }
+
+// stubWindow is a window of a stubSession.
+type stubWindow struct {
+	stubSession
+	owner string
+}
+
+func (w stubWindow) Owner() string { return w.owner }
+
+// stubTmux records switched clients.
+type stubTmux struct {
+	switched chan [2]string
+}
+
+func (t stubTmux) SwitchClient(client, target string) error {
+	t.switched <- [2]string{client, target}
+	return nil
+}
+
+func (t stubTmux) CapturePane(target string) (string, error) {
+	return "$ pane of " + target, nil
+}
+
+func TestServer(t *testing.T) {
+	// Unix socket paths are limited in length, so avoid the long per-test temporary directory.
+	dir, err := os.MkdirTemp("", "treemux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "sock")
+
+	lister := stubLister{
+		stubSession{name: "treemux", path: "/src/treemux", group: []string{"treemux", "main"}},
+		stubWindow{stubSession: stubSession{name: "treemux:1", path: "/src/treemux"}, owner: "treemux"},
+	}
+	tmux := stubTmux{switched: make(chan [2]string, 1)}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		server := NewServer(lister, tmux, time.Hour)
+		server.Options = IndexOptions{Windows: true}
+		served <- server.ListenAndServe(ctx, path)
+	}()
+
+	var client *Client
+	for start := time.Now(); client == nil; {
+		if client, err = Dial(path); err != nil {
+			if time.Since(start) > 5*time.Second {
+				t.Fatalf("failed to dial daemon: %v", err)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Clients learn how the index was built, to tell whether it is the list they would show.
+	if options := client.Options(); !options.Windows {
+		t.Fatalf("expected the index options, got %+v", options)
+	}
+
+	sessions, err := client.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	want := []Session{
+		{Name: "treemux", Path: "/src/treemux", Group: []string{"treemux", "main"}, Label: "  treemux"},
+		{Name: "treemux:1", Path: "/src/treemux", Label: "  treemux:1", Owner: "treemux"},
+	}
+	if !reflect.DeepEqual(sessions, want) {
+		t.Fatalf("expected %+v, got %+v", want, sessions)
+	}
+
+	if err := client.Attach("treemux", "/dev/pts/1"); err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	if got := <-tmux.switched; got != [2]string{"/dev/pts/1", "treemux"} {
+		t.Fatalf("expected switch of /dev/pts/1 to treemux, got %v", got)
+	}
+	if preview, err := client.Preview("treemux"); err != nil || preview != "$ pane of treemux" {
+		t.Fatalf("expected preview, got %q, %v", preview, err)
+	}
+
+	// Clients speaking another protocol version are rejected.
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(`{"version":0,"method":"ping"}` + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 256)
+	n, err := conn.Read(buf)
+	if err != nil || !strings.Contains(string(buf[:n]), "unsupported protocol version") {
+		t.Fatalf("expected version mismatch error, got %q, %v", buf[:n], err)
+	}
+
+	// A second daemon refuses to replace a live one.
+	if err := NewServer(lister, tmux, time.Hour).ListenAndServe(ctx, path); err == nil {
+		t.Fatal("expected a second daemon to fail")
+	}
+
+	cancel()
+	if err := <-served; err != nil {
+		t.Fatalf("ListenAndServe: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected the socket to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,326 @@
+// Package executor runs the external commands treemux depends on, tmux and git, through a single
+// place.
+//
+// Every command is traced with log/slog at debug level: its argv, working directory, duration,
+// exit code and a truncated stderr. An executor can also record a transcript of the commands it
+// runs and their results, and replay a transcript instead of running anything, which turns a bug
+// report into a reproducible test.
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxLoggedStderr bounds how much of a command's stderr is logged.
+const maxLoggedStderr = 200
+
+// waitDelay bounds how long a canceled command's output is still read. Without it, a process the
+// command started that still holds its stdout or stderr open would block Run until it exits.
+const waitDelay = time.Second
+
+// Command is an external command.
+type Command struct {
+	// Name is the program to run, looked up in PATH.
+	Name string
+
+	Args []string
+
+	// Dir is the working directory, or "" for the current one.
+	Dir string
+
+	// Env holds extra environment variables, e.g. "GIT_TERMINAL_PROMPT=0".
+	Env []string
+
+	// Stdin is written to the command's standard input. Transcripts record the output only, so a
+	// replayed command gets the recorded output whatever its input.
+	Stdin string
+}
+
+// String returns the command line.
+func (c Command) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// ExitError reports a command that ran and exited with a non-zero status.
+type ExitError struct {
+	// Code is the exit status, or -1 if the command was killed by a signal.
+	Code int
+
+	// Err is the error from the process, which is nil for replayed commands.
+	Err error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// Stats summarizes the commands an executor has run.
+type Stats struct {
+	Commands int
+	Duration time.Duration
+}
+
+// Executor runs commands.
+type Executor struct {
+	mu       sync.Mutex
+	stats    Stats
+	recorder *recorder
+	replay   *replayer
+
+	// parent, if set, counts the commands of a forked executor too.
+	parent *Executor
+}
+
+// Option configures an Executor.
+type Option func(*Executor)
+
+// New returns an executor configured by options.
+func New(options ...Option) *Executor {
+	e := &Executor{}
+	for _, option := range options {
+		option(e)
+	}
+	return e
+}
+
+// Fork returns an executor that shares e's transcript and is counted by e, but also counts its own
+// commands, e.g. to tell the commands of one lister from those of others running at the same time.
+func (e *Executor) Fork() *Executor {
+	return &Executor{recorder: e.recorder, replay: e.replay, parent: e}
+}
+
+// defaultExecutor runs the commands of the package-level functions.
+var (
+	defaultMu       sync.RWMutex
+	defaultExecutor = New()
+)
+
+// Default returns the executor used by the package-level functions.
+func Default() *Executor {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultExecutor
+}
+
+// SetDefault makes e the executor used by the package-level functions.
+func SetDefault(e *Executor) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultExecutor = e
+}
+
+// Run runs cmd with the default executor.
+func Run(ctx context.Context, cmd Command) (stdout, stderr string, err error) {
+	return Default().Run(ctx, cmd)
+}
+
+// Exec replaces the process with cmd using the default executor.
+func Exec(cmd Command) error {
+	return Default().Exec(cmd)
+}
+
+// Run runs cmd, killing it when ctx is done, and returns its output. A command that exits with a
+// non-zero status returns an *ExitError; one that cannot be found returns an error matching
+// exec.ErrNotFound.
+func (e *Executor) Run(ctx context.Context, cmd Command) (stdout, stderr string, err error) {
+	start := time.Now()
+	var result Result
+	if e.replay != nil {
+		result = e.replay.next(cmd)
+	} else {
+		result = run(ctx, cmd)
+	}
+	duration := time.Since(start)
+
+	e.count(duration)
+	if e.recorder != nil {
+		e.recorder.write(cmd, result)
+	}
+	logCommand(cmd, result, duration)
+	return result.Stdout, result.Stderr, result.err()
+}
+
+// Exec replaces the process with cmd, as when attaching to tmux. Nothing is executed while
+// replaying, and the command is only recorded.
+func (e *Executor) Exec(cmd Command) error {
+	if e.recorder != nil {
+		e.recorder.write(cmd, Result{})
+	}
+	logCommand(cmd, Result{}, 0)
+	if e.replay != nil {
+		e.replay.next(cmd)
+		return nil
+	}
+	path, err := exec.LookPath(cmd.Name)
+	if err != nil {
+		return err
+	}
+	if cmd.Dir != "" {
+		if err := os.Chdir(cmd.Dir); err != nil {
+			return err
+		}
+	}
+	return syscall.Exec(path, append([]string{cmd.Name}, cmd.Args...), append(os.Environ(), cmd.Env...))
+}
+
+// count adds a command that ran for duration to the stats of e and the executors it was forked from.
+func (e *Executor) count(duration time.Duration) {
+	for ; e != nil; e = e.parent {
+		e.mu.Lock()
+		e.stats.Commands++
+		e.stats.Duration += duration
+		e.mu.Unlock()
+	}
+}
+
+// Stats returns the number of commands run so far and the time spent running them.
+func (e *Executor) Stats() Stats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.stats
+}
+
+// run runs cmd as a child process. A command that can be canceled runs in a process group of its
+// own, and canceling ctx kills the whole group, so that processes it started, e.g. by a plugin's
+// shell or by git fetch, cannot outlive a timeout. Other commands stay in treemux's group, where
+// an interactive selector can still read the terminal.
+func run(ctx context.Context, cmd Command) Result {
+	c := exec.CommandContext(ctx, cmd.Name, cmd.Args...)
+	if ctx.Done() != nil {
+		c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		c.Cancel = func() error {
+			return syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
+		}
+		c.WaitDelay = waitDelay
+	}
+	c.Dir = cmd.Dir
+	if len(cmd.Env) > 0 {
+		c.Env = append(os.Environ(), cmd.Env...)
+	}
+	if cmd.Stdin != "" {
+		c.Stdin = strings.NewReader(cmd.Stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	err := c.Run()
+	return newResult(stdout.String(), stderr.String(), err)
+}
+
+// newResult returns the result of a live command that wrote stdout and stderr and failed with err.
+func newResult(stdout, stderr string, err error) Result {
+	result := Result{Stdout: stdout, Stderr: stderr}
+	if exitErr, ok := errors.AsType[*exec.ExitError](err); ok {
+		result.ExitCode = exitErr.ExitCode()
+		result.runErr = &ExitError{Code: result.ExitCode, Err: exitErr}
+	} else if err != nil {
+		result.ExitCode = -1
+		result.Error = err.Error()
+		result.NotFound = errors.Is(err, exec.ErrNotFound)
+		result.runErr = err
+	}
+	return result
+}
+
+// Process is a long-running command started by Start, which the caller talks to over its standard
+// input and output.
+type Process struct {
+	Stdin  io.WriteCloser
+	Stdout io.ReadCloser
+
+	executor *Executor
+	cmd      Command
+	c        *exec.Cmd
+	stderr   bytes.Buffer
+	start    time.Time
+}
+
+// Start starts cmd with the default executor.
+func Start(cmd Command) (*Process, error) {
+	return Default().Start(cmd)
+}
+
+// Start starts cmd in the background, such as a tmux control-mode client. The process is traced
+// and counted like the commands of Run once Wait returns. A transcript cannot hold a conversation
+// with a process, so it is not recorded, and Start fails while replaying.
+func (e *Executor) Start(cmd Command) (*Process, error) {
+	if e.replay != nil {
+		return nil, fmt.Errorf("cannot start %s while replaying a transcript", cmd.Name)
+	}
+	c := exec.Command(cmd.Name, cmd.Args...)
+	c.Dir = cmd.Dir
+	if len(cmd.Env) > 0 {
+		c.Env = append(os.Environ(), cmd.Env...)
+	}
+	p := &Process{executor: e, cmd: cmd, c: c}
+	c.Stderr = &p.stderr
+	var err error
+	if p.Stdin, err = c.StdinPipe(); err != nil {
+		return nil, err
+	}
+	if p.Stdout, err = c.StdoutPipe(); err != nil {
+		return nil, err
+	}
+	p.start = time.Now()
+	if err := c.Start(); err != nil {
+		logCommand(cmd, newResult("", "", err), 0)
+		return nil, err
+	}
+	return p, nil
+}
+
+// Wait waits for the process to exit once its standard output has been read, and returns what it
+// wrote to stderr. A process that exits with a non-zero status returns an *ExitError.
+func (p *Process) Wait() (stderr string, err error) {
+	result := newResult("", "", p.c.Wait())
+	result.Stderr = p.stderr.String()
+	duration := time.Since(p.start)
+	p.executor.count(duration)
+	logCommand(p.cmd, result, duration)
+	return result.Stderr, result.err()
+}
+
+// logCommand traces a command at debug level.
+func logCommand(cmd Command, result Result, duration time.Duration) {
+	if !slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	dir := cmd.Dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	attrs := []any{
+		slog.Any("argv", append([]string{cmd.Name}, cmd.Args...)),
+		slog.String("cwd", dir),
+		slog.Duration("duration", duration),
+		slog.Int("exit_code", result.ExitCode),
+	}
+	if stderr := strings.TrimSpace(result.Stderr); stderr != "" {
+		if len(stderr) > maxLoggedStderr {
+			stderr = stderr[:maxLoggedStderr] + "…"
+		}
+		attrs = append(attrs, slog.String("stderr", stderr))
+	}
+	if result.Error != "" {
+		attrs = append(attrs, slog.String("error", result.Error))
+	}
+	slog.Debug("exec", attrs...)
+}
@@ -0,0 +1,144 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+	commands := []Command{
+		{Name: "sh", Args: []string{"-c", "echo out; echo err >&2"}},
+		{Name: "sh", Args: []string{"-c", "exit 3"}},
+		{Name: "sh", Args: []string{"-c", "pwd"}, Dir: "/"},
+		{Name: "treemux-missing-command"},
+	}
+	check := func(t *testing.T, e *Executor) {
+		t.Helper()
+		stdout, stderr, err := e.Run(context.Background(), commands[0])
+		if stdout != "out\n" || stderr != "err\n" || err != nil {
+			t.Fatalf("expected output on both streams, got %q, %q, %v", stdout, stderr, err)
+		}
+		_, _, err = e.Run(context.Background(), commands[1])
+		if exitErr, ok := errors.AsType[*ExitError](err); !ok || exitErr.Code != 3 {
+			t.Fatalf("expected exit code 3, got %v", err)
+		}
+		if stdout, _, _ := e.Run(context.Background(), commands[2]); stdout != "/\n" {
+			t.Fatalf("expected the command to run in /, got %q", stdout)
+		}
+		if _, _, err := e.Run(context.Background(), commands[3]); !errors.Is(err, exec.ErrNotFound) {
+			t.Fatalf("expected exec.ErrNotFound, got %v", err)
+		}
+	}
+
+	var transcript bytes.Buffer
+	recording := New(WithRecord(&transcript))
+	check(t, recording)
+	if stats := recording.Stats(); stats.Commands != len(commands) {
+		t.Fatalf("expected %d commands in the stats, got %d", len(commands), stats.Commands)
+	}
+
+	entries, err := ReadTranscript(&transcript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(commands) {
+		t.Fatalf("expected %d transcript entries, got %d", len(commands), len(entries))
+	}
+	replaying := New(WithReplay(entries))
+	check(t, replaying)
+
+	// Every entry has been used, so running a command again fails instead of running it.
+	if _, _, err := replaying.Run(context.Background(), commands[0]); err == nil {
+		t.Fatal("expected a command without a transcript entry to fail")
+	}
+}
+
+func TestDebugLog(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	e := New(WithReplay([]Entry{{
+		Name:   "git",
+		Args:   []string{"status"},
+		Dir:    "/repo",
+		Result: Result{ExitCode: 128, Stderr: strings.Repeat("x", 2*maxLoggedStderr)},
+	}}))
+	if _, _, err := e.Run(context.Background(), Command{Name: "git", Args: []string{"status"}, Dir: "/repo"}); err == nil {
+		t.Fatal("expected the recorded failure")
+	}
+	line := logs.String()
+	for _, want := range []string{"msg=exec", `argv="[git status]"`, "cwd=/repo", "exit_code=128", "duration="} {
+		if !strings.Contains(line, want) {
+			t.Fatalf("expected %q in %q", want, line)
+		}
+	}
+	if strings.Contains(line, strings.Repeat("x", maxLoggedStderr+1)) {
+		t.Fatalf("expected stderr to be truncated, got %q", line)
+	}
+}
+
+func TestForkAndStart(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+	parent := New()
+	fork := parent.Fork()
+	if _, _, err := parent.Run(context.Background(), Command{Name: "sh", Args: []string{"-c", "true"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A started process talks over its pipes and is counted once it has exited.
+	process, err := fork.Start(Command{Name: "sh", Args: []string{"-c", "read line; echo \"got $line\"; echo bye >&2; exit 2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := process.Stdin.Write([]byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	var output bytes.Buffer
+	if _, err := output.ReadFrom(process.Stdout); err != nil {
+		t.Fatal(err)
+	}
+	stderr, err := process.Wait()
+	if exitErr, ok := errors.AsType[*ExitError](err); !ok || exitErr.Code != 2 || stderr != "bye\n" || output.String() != "got hello\n" {
+		t.Fatalf("expected the conversation and exit code 2, got %q, %q, %v", output.String(), stderr, err)
+	}
+	if got := fork.Stats().Commands; got != 1 {
+		t.Fatalf("expected the fork to count its own command, got %d", got)
+	}
+	if got := parent.Stats().Commands; got != 2 {
+		t.Fatalf("expected the parent to count both commands, got %d", got)
+	}
+
+	if _, err := New(WithReplay(nil)).Start(Command{Name: "sh"}); err == nil {
+		t.Fatal("expected Start to fail while replaying")
+	}
+}
+
+func TestRunKillsProcessGroup(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+	// The shell's child keeps stdout open, so killing the shell alone would leave Run waiting.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := Run(ctx, Command{Name: "sh", Args: []string{"-c", "sleep 5; echo done"}})
+	if err == nil {
+		t.Fatal("expected the command to be killed")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("expected Run to return soon after the timeout, took %s", elapsed)
+	}
+}
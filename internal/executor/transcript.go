@@ -0,0 +1,142 @@
+package executor
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"sync"
+)
+
+// Result is the outcome of a command, as recorded in a transcript.
+type Result struct {
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	ExitCode int    `json:"exit_code,omitempty"`
+
+	// Error describes a command that could not be run, e.g. because it is not installed.
+	Error string `json:"error,omitempty"`
+
+	// NotFound is set when the command is not installed.
+	NotFound bool `json:"not_found,omitempty"`
+
+	// runErr is the error returned to the caller of a live command.
+	runErr error
+}
+
+// err returns the error a caller sees for the result.
+func (r Result) err() error {
+	switch {
+	case r.runErr != nil:
+		return r.runErr
+	case r.NotFound:
+		return &replayedError{message: r.Error, err: exec.ErrNotFound}
+	case r.Error != "":
+		return errors.New(r.Error)
+	case r.ExitCode != 0:
+		return &ExitError{Code: r.ExitCode}
+	default:
+		return nil
+	}
+}
+
+// replayedError is a recorded error that keeps matching the sentinel it matched when recorded.
+type replayedError struct {
+	message string
+	err     error
+}
+
+func (e *replayedError) Error() string { return e.message }
+func (e *replayedError) Unwrap() error { return e.err }
+
+// Entry is a command and its result in a transcript. A transcript is a file of entries, one JSON
+// object per line, in the order the commands finished.
+type Entry struct {
+	Name string   `json:"name"`
+	Args []string `json:"args"`
+	Dir  string   `json:"dir,omitempty"`
+	Result
+}
+
+// matches reports whether the entry was recorded for cmd.
+func (e Entry) matches(cmd Command) bool {
+	return e.Name == cmd.Name && slices.Equal(e.Args, cmd.Args) && e.Dir == cmd.Dir
+}
+
+// recorder appends entries to a transcript.
+type recorder struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// WithRecord appends a transcript entry to w for every command run. Entries are written as soon as
+// commands finish, so that a transcript survives treemux replacing itself with tmux.
+func WithRecord(w io.Writer) Option {
+	return func(e *Executor) {
+		e.recorder = &recorder{w: w}
+	}
+}
+
+// write appends the entry for cmd.
+func (r *recorder) write(cmd Command, result Result) {
+	line, err := json.Marshal(Entry{Name: cmd.Name, Args: cmd.Args, Dir: cmd.Dir, Result: result})
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, _ = r.w.Write(append(line, '\n'))
+}
+
+// ReadTranscript reads the entries of a transcript.
+func ReadTranscript(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid transcript entry on line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transcript: %w", err)
+	}
+	return entries, nil
+}
+
+// replayer answers commands from a transcript.
+type replayer struct {
+	mu      sync.Mutex
+	entries []Entry
+	used    []bool
+}
+
+// WithReplay answers every command from entries instead of running it. Each command gets the
+// result of the first unused entry recorded for the same command, so commands that ran
+// concurrently may be replayed in another order. A command without an entry fails.
+func WithReplay(entries []Entry) Option {
+	return func(e *Executor) {
+		e.replay = &replayer{entries: entries, used: make([]bool, len(entries))}
+	}
+}
+
+// next returns the recorded result for cmd.
+func (r *replayer) next(cmd Command) Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, entry := range r.entries {
+		if !r.used[i] && entry.matches(cmd) {
+			r.used[i] = true
+			return entry.Result
+		}
+	}
+	return Result{ExitCode: -1, Error: fmt.Sprintf("replay: no transcript entry for %q", cmd.String())}
+}
@@ -0,0 +1,77 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrGitNotInstalled is returned when the git binary cannot be found.
+	ErrGitNotInstalled = errors.New("git is not installed")
+
+	// ErrNotRepository is returned when a directory is not inside a git repository.
+	ErrNotRepository = errors.New("not inside a git repository")
+
+	// ErrRefNotFound is returned when a branch, ref or revision does not exist.
+	ErrRefNotFound = errors.New("ref not found")
+
+	// ErrDirtyWorktree is returned when git refuses to act because of local changes.
+	ErrDirtyWorktree = errors.New("worktree has local changes")
+)
+
+// Error describes a failed git invocation. It matches one of the sentinel errors above with
+// errors.Is when git's failure can be classified.
+type Error struct {
+	// Args are the arguments git was invoked with.
+	Args []string
+
+	// ExitCode is git's exit status, or -1 if git did not run to completion.
+	ExitCode int
+
+	// Stderr is git's trimmed standard error output.
+	Stderr string
+
+	// Err is the underlying error from running the command.
+	Err error
+
+	// kind is the sentinel error this failure was classified as, if any.
+	kind error
+}
+
+// Error returns the failed command along with git's error output.
+func (e *Error) Error() string {
+	errText := e.Stderr
+	if errText == "" {
+		errText = e.Err.Error()
+	}
+	return fmt.Sprintf("git %s: %s", strings.Join(e.Args, " "), errText)
+}
+
+// Unwrap returns the underlying error and the classified sentinel error.
+func (e *Error) Unwrap() []error {
+	if e.kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Err, e.kind}
+}
+
+// classify maps git's error output to a sentinel error.
+func classify(stderr string) error {
+	switch {
+	case strings.Contains(stderr, "not a git repository"):
+		return ErrNotRepository
+	case strings.Contains(stderr, "not a valid ref"),
+		strings.Contains(stderr, "unknown revision"),
+		strings.Contains(stderr, "Needed a single revision"),
+		strings.Contains(stderr, "invalid reference"),
+		strings.Contains(stderr, "couldn't find remote ref"):
+		return ErrRefNotFound
+	case strings.Contains(stderr, "contains modified or untracked files"),
+		strings.Contains(stderr, "local changes to the following files would be overwritten"),
+		strings.Contains(stderr, "untracked working tree files would be overwritten"):
+		return ErrDirtyWorktree
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,137 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ian-howell/treemux/internal/state"
+)
+
+// FetchPolicy controls when treemux is allowed to fetch from a remote.
+type FetchPolicy string
+
+const (
+	// FetchNever never touches the network and relies on remote-tracking refs already present.
+	FetchNever FetchPolicy = "never"
+
+	// FetchIfStale fetches a branch only when it has not been fetched within FetchOptions.MaxAge.
+	FetchIfStale FetchPolicy = "if-stale"
+
+	// FetchAlways fetches a branch every time it is needed.
+	FetchAlways FetchPolicy = "always"
+)
+
+// fetchStateFile is the state file recording when each repository ref was last fetched.
+const fetchStateFile = "fetch.json"
+
+// FetchOptions configures how EnsureBranch fetches branches that do not exist locally.
+type FetchOptions struct {
+	// Policy decides whether a fetch happens at all.
+	Policy FetchPolicy
+
+	// MaxAge is how long a previous fetch stays fresh under FetchIfStale.
+	MaxAge time.Duration
+
+	// Timeout bounds a single fetch. Zero means no timeout.
+	Timeout time.Duration
+
+	// Remote is the remote to fetch from. When empty, the remote that already tracks the branch is
+	// used, then origin, then the first configured remote.
+	Remote string
+
+	// Warn, if set, receives the fetch failures that FetchIfStale recovers from by using the
+	// remote-tracking ref already present, e.g. when offline.
+	Warn func(error)
+}
+
+// DefaultFetchOptions returns the fetch options used when none are configured.
+func DefaultFetchOptions() FetchOptions {
+	return FetchOptions{
+		Policy:  FetchIfStale,
+		MaxAge:  15 * time.Minute,
+		Timeout: 30 * time.Second,
+	}
+}
+
+// ParseFetchPolicy parses a fetch policy name.
+func ParseFetchPolicy(s string) (FetchPolicy, error) {
+	switch policy := FetchPolicy(strings.TrimSpace(s)); policy {
+	case FetchNever, FetchIfStale, FetchAlways:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown fetch policy %q (want %q, %q or %q)", s, FetchNever, FetchIfStale, FetchAlways)
+	}
+}
+
+// shouldFetch reports whether the branch on remote needs to be fetched under the given options.
+func shouldFetch(repoRoot, remote, branch string, opts FetchOptions) bool {
+	switch opts.Policy {
+	case FetchAlways:
+		return true
+	case FetchIfStale:
+		last, ok := lastFetch(repoRoot, remote, branch)
+		return !ok || time.Since(last) > opts.MaxAge
+	default:
+		return false
+	}
+}
+
+// fetchBranch fetches a single branch from remote into its remote-tracking ref. It reports false
+// without an error when the remote does not have the branch.
+func fetchBranch(repoRoot, remote, branch string, timeout time.Duration) (bool, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	refspec := fmt.Sprintf("+refs/heads/%s:refs/remotes/%s/%s", branch, remote, branch)
+	_, err := runGitContext(ctx, repoRoot, "fetch", "--no-tags", remote, refspec)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return false, fmt.Errorf("fetching %s from %s timed out after %s", branch, remote, timeout)
+	}
+	if err != nil {
+		if errors.Is(err, ErrRefNotFound) {
+			recordFetch(repoRoot, remote, branch)
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to fetch %s from %s: %w", branch, remote, err)
+	}
+	recordFetch(repoRoot, remote, branch)
+	return true, nil
+}
+
+// fetchTimes maps a repository root to the last fetch time of each remote/branch ref in it.
+type fetchTimes map[string]map[string]time.Time
+
+// lastFetch returns when remote/branch was last fetched in the repository.
+func lastFetch(repoRoot, remote, branch string) (time.Time, bool) {
+	times := fetchTimes{}
+	if err := state.ReadJSON(fetchStateFile, &times); err != nil {
+		return time.Time{}, false
+	}
+	last, ok := times[repoRoot][remote+"/"+branch]
+	return last, ok
+}
+
+// recordFetch stores the current time as the last fetch of remote/branch in the repository. The
+// time only spares if-stale fetches, so failing to record it is logged rather than returned.
+func recordFetch(repoRoot, remote, branch string) {
+	ref := remote + "/" + branch
+	times := fetchTimes{}
+	if err := state.ReadJSON(fetchStateFile, &times); err != nil {
+		slog.Warn("failed to record fetch time", slog.String("ref", ref), slog.Any("error", err))
+		return
+	}
+	if times[repoRoot] == nil {
+		times[repoRoot] = map[string]time.Time{}
+	}
+	times[repoRoot][ref] = time.Now()
+	if err := state.WriteJSON(fetchStateFile, times); err != nil {
+		slog.Warn("failed to record fetch time", slog.String("ref", ref), slog.Any("error", err))
+	}
+}
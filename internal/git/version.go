@@ -0,0 +1,63 @@
+package git
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MinWorktreeVersion is the oldest git treemux's worktree commands work with: they rely on
+// `rev-parse --path-format`, added in git 2.31.
+const MinWorktreeVersion = "2.31"
+
+// Version returns the version of the installed git, e.g. "2.39.2" or "2.39.3 (Apple Git-146)".
+func Version() (string, error) {
+	output, err := runGit("", "version")
+	if err != nil {
+		return "", err
+	}
+	version, ok := strings.CutPrefix(output, "git version ")
+	if !ok {
+		return "", fmt.Errorf("unexpected git version %q", output)
+	}
+	return version, nil
+}
+
+// SupportsWorktrees reports whether git at version is recent enough for treemux's worktree
+// commands.
+func SupportsWorktrees(version string) bool {
+	return !versionLess(version, MinWorktreeVersion)
+}
+
+// versionLess compares the leading numeric components of two dotted versions.
+func versionLess(a, b string) bool {
+	as, bs := versionNumbers(a), versionNumbers(b)
+	for i := range max(len(as), len(bs)) {
+		var x, y int
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		if x != y {
+			return x < y
+		}
+	}
+	return false
+}
+
+// versionNumbers parses the numbers of a version such as "2.39.2.windows.1", stopping at the first
+// component that is not a number.
+func versionNumbers(version string) []int {
+	version, _, _ = strings.Cut(version, " ")
+	var numbers []int
+	for _, part := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			break
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers
+}
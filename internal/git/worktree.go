@@ -2,28 +2,82 @@
 package git
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+
+	"github.com/ian-howell/treemux/internal/executor"
 )
 
 // RepositoryRoot returns the repository root directory for the given path.
 func RepositoryRoot(dir string) (string, error) {
 	output, err := runGit(dir, "rev-parse", "--show-toplevel")
 	if err != nil {
-		return "", fmt.Errorf("not inside a git repository")
+		return "", err
 	}
 	if output == "" {
-		return "", fmt.Errorf("not inside a git repository")
+		// Inside a bare repository or a .git directory there is no worktree to report.
+		return "", ErrNotRepository
 	}
 	return output, nil
 }
 
-// EnsureWorktree creates a worktree if it does not already exist.
-func EnsureWorktree(repoRoot, branch, path string) error {
+// MainWorktreeRoot returns the root of the main worktree of the repository containing dir. It
+// differs from RepositoryRoot when dir is inside a linked worktree.
+func MainWorktreeRoot(dir string) (string, error) {
+	output, err := runGit(dir, "rev-parse", "--path-format=absolute", "--git-common-dir")
+	if err != nil {
+		return "", err
+	}
+	if filepath.Base(output) != ".git" {
+		// Bare repositories have no main worktree.
+		return "", ErrNotRepository
+	}
+	return filepath.Dir(output), nil
+}
+
+// WorktreeInfo describes the worktree containing a directory.
+type WorktreeInfo struct {
+	// Root is the root of the worktree.
+	Root string
+
+	// MainRoot is the root of the repository's main worktree. It equals Root outside linked
+	// worktrees.
+	MainRoot string
+
+	// Branch is the checked out branch, or "" when HEAD is detached.
+	Branch string
+}
+
+// Worktree describes the worktree containing dir.
+func Worktree(dir string) (WorktreeInfo, error) {
+	output, err := runGit(dir, "rev-parse", "--path-format=absolute", "--show-toplevel", "--git-common-dir", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return WorktreeInfo{}, err
+	}
+	lines := strings.Split(output, "\n")
+	if len(lines) != 3 {
+		return WorktreeInfo{}, ErrNotRepository
+	}
+	info := WorktreeInfo{
+		Root:     lines[0],
+		MainRoot: filepath.Dir(lines[1]),
+		Branch:   lines[2],
+	}
+	if info.Branch == "HEAD" {
+		info.Branch = ""
+	}
+	return info, nil
+}
+
+// EnsureWorktree creates a worktree if it does not already exist. The fetch options are used if
+// the branch has to be created.
+func EnsureWorktree(repoRoot, branch, path string, fetch FetchOptions) error {
 	if repoRoot == "" || branch == "" || path == "" {
 		return fmt.Errorf("missing worktree parameters")
 	}
@@ -33,7 +87,7 @@ func EnsureWorktree(repoRoot, branch, path string) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return fmt.Errorf("failed to create worktree parent dir: %w", err)
 	}
-	if err := EnsureBranch(repoRoot, branch); err != nil {
+	if err := EnsureBranch(repoRoot, branch, fetch); err != nil {
 		return err
 	}
 	if _, err := runGit(repoRoot, "worktree", "add", path, branch); err != nil {
@@ -43,7 +97,10 @@ func EnsureWorktree(repoRoot, branch, path string) error {
 }
 
 // EnsureBranch ensures a local branch exists, creating or tracking as needed.
-func EnsureBranch(repoRoot, branch string) error {
+//
+// A branch that already exists locally never touches the network. Otherwise the branch is fetched
+// from a single remote according to the fetch options before deciding whether to track it.
+func EnsureBranch(repoRoot, branch string, fetch FetchOptions) error {
 	if repoRoot == "" || branch == "" {
 		return fmt.Errorf("missing branch parameters")
 	}
@@ -60,13 +117,30 @@ func EnsureBranch(repoRoot, branch string) error {
 		return err
 	}
 	if len(remotes) > 0 {
-		if _, err := runGit(repoRoot, "fetch", "--all", "--prune"); err != nil {
+		remote, err := remoteWithBranch(repoRoot, branch, fetch.Remote)
+		if err != nil {
 			return err
 		}
-		remote, err := firstRemoteWithBranch(repoRoot, branch)
+		fetchRemote, err := chooseFetchRemote(remotes, remote, fetch.Remote)
 		if err != nil {
 			return err
 		}
+		if shouldFetch(repoRoot, fetchRemote, branch, fetch) {
+			found, err := fetchBranch(repoRoot, fetchRemote, branch, fetch.Timeout)
+			switch {
+			case err != nil && fetch.Policy == FetchIfStale && remote != "":
+				// A stale remote-tracking ref beats no worktree at all.
+				if fetch.Warn != nil {
+					fetch.Warn(fmt.Errorf("%w; using %s/%s as it is", err, remote, branch))
+				}
+			case err != nil:
+				return err
+			case found:
+				remote = fetchRemote
+			case remote == fetchRemote:
+				remote = ""
+			}
+		}
 		if remote != "" {
 			if _, err := runGit(repoRoot, "branch", "--track", branch, remote+"/"+branch); err != nil {
 				return err
@@ -80,23 +154,36 @@ func EnsureBranch(repoRoot, branch string) error {
 	return nil
 }
 
-func hasLocalBranch(repoRoot, branch string) (bool, error) {
-	_, err := runGit(repoRoot, "show-ref", "--verify", "refs/heads/"+branch)
-	if err == nil {
-		return true, nil
+// Branches returns the names of the local branches.
+func Branches(repoRoot string) ([]string, error) {
+	output, err := runGit(repoRoot, "for-each-ref", "--format=%(refname:short)", "refs/heads")
+	if err != nil {
+		return nil, err
 	}
-	if strings.Contains(err.Error(), "not a valid ref") || strings.Contains(err.Error(), "fatal") {
-		return false, nil
+	if output == "" {
+		return []string{}, nil
 	}
-	return false, err
+	return strings.Split(output, "\n"), nil
+}
+
+// hasLocalBranch reports whether the branch exists locally.
+func hasLocalBranch(repoRoot, branch string) (bool, error) {
+	return hasRef(repoRoot, "refs/heads/"+branch)
 }
 
+// hasRemoteBranch reports whether a remote-tracking ref for the branch exists on remote.
 func hasRemoteBranch(repoRoot, remote, branch string) (bool, error) {
-	_, err := runGit(repoRoot, "show-ref", "--verify", "refs/remotes/"+remote+"/"+branch)
+	return hasRef(repoRoot, "refs/remotes/"+remote+"/"+branch)
+}
+
+// hasRef reports whether the fully qualified ref exists. `show-ref --verify --quiet` exits with 1
+// only when the ref is missing, so any other failure is a real error.
+func hasRef(repoRoot, ref string) (bool, error) {
+	_, err := runGit(repoRoot, "show-ref", "--verify", "--quiet", ref)
 	if err == nil {
 		return true, nil
 	}
-	if strings.Contains(err.Error(), "not a valid ref") || strings.Contains(err.Error(), "fatal") {
+	if gitErr, ok := errors.AsType[*Error](err); ok && gitErr.ExitCode == 1 {
 		return false, nil
 	}
 	return false, err
@@ -126,6 +213,19 @@ func listRemotes(repoRoot string) ([]string, error) {
 	return remotes, nil
 }
 
+// remoteWithBranch returns the remote whose remote-tracking refs already contain the branch. When
+// preferred is set only that remote is considered.
+func remoteWithBranch(repoRoot, branch, preferred string) (string, error) {
+	if preferred == "" {
+		return firstRemoteWithBranch(repoRoot, branch)
+	}
+	ok, err := hasRemoteBranch(repoRoot, preferred, branch)
+	if err != nil || !ok {
+		return "", err
+	}
+	return preferred, nil
+}
+
 func firstRemoteWithBranch(repoRoot, branch string) (string, error) {
 	remotes, err := listRemotes(repoRoot)
 	if err != nil {
@@ -154,21 +254,51 @@ func firstRemoteWithBranch(repoRoot, branch string) (string, error) {
 	return "", nil
 }
 
+// chooseFetchRemote picks the remote a missing branch is fetched from.
+func chooseFetchRemote(remotes []string, known, preferred string) (string, error) {
+	if preferred != "" {
+		if !slices.Contains(remotes, preferred) {
+			return "", fmt.Errorf("remote %q is not configured", preferred)
+		}
+		return preferred, nil
+	}
+	if known != "" {
+		return known, nil
+	}
+	if slices.Contains(remotes, "origin") {
+		return "origin", nil
+	}
+	return remotes[0], nil
+}
+
 // runGit executes git with the provided arguments.
 func runGit(dir string, args ...string) (string, error) {
-	cmd := exec.Command("git", args...)
-	if dir != "" {
-		cmd.Dir = dir
-	}
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
-		errText := strings.TrimSpace(stderr.String())
-		if errText == "" {
-			errText = err.Error()
+	return runGitContext(context.Background(), dir, args...)
+}
+
+// runGitContext executes git with the provided arguments, killing it when ctx is done.
+func runGitContext(ctx context.Context, dir string, args ...string) (string, error) {
+	stdout, stderr, err := executor.Run(ctx, executor.Command{
+		Name: "git",
+		Args: args,
+		Dir:  dir,
+		// Never block on a credential prompt; treemux owns the terminal.
+		Env: []string{"GIT_TERMINAL_PROMPT=0"},
+	})
+	if err != nil {
+		gitErr := &Error{
+			Args:     args,
+			ExitCode: -1,
+			Stderr:   strings.TrimSpace(stderr),
+			Err:      err,
+		}
+		if errors.Is(err, exec.ErrNotFound) {
+			gitErr.kind = ErrGitNotInstalled
+		} else if exitErr, ok := errors.AsType[*executor.ExitError](err); ok {
+			gitErr.ExitCode = exitErr.Code
+			gitErr.kind = classify(gitErr.Stderr)
 		}
-		return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), errText)
+		return "", gitErr
 	}
-	return strings.TrimSpace(stdout.String()), nil
+	return strings.TrimSpace(stdout), nil
 }
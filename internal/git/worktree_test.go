@@ -0,0 +1,192 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ian-howell/treemux/internal/state"
+)
+
+func TestEnsureBranchFetchNeverSkipsNetwork(t *testing.T) {
+	repo := newTestRepo(t)
+	// A remote that cannot be reached makes any fetch fail.
+	gitCmd(t, repo, "remote", "add", "origin", filepath.Join(t.TempDir(), "missing"))
+
+	opts := FetchOptions{Policy: FetchNever}
+	if err := EnsureBranch(repo, "feature", opts); err != nil {
+		t.Fatalf("expected branch creation without fetching, got %v", err)
+	}
+	if ok, err := hasLocalBranch(repo, "feature"); err != nil || !ok {
+		t.Fatalf("expected local branch to exist, got %v, %v", ok, err)
+	}
+
+	opts.Policy = FetchAlways
+	if err := EnsureBranch(repo, "other", opts); err == nil {
+		t.Fatalf("expected fetch from unreachable remote to fail")
+	}
+}
+
+func TestEnsureBranchTracksFetchedBranch(t *testing.T) {
+	repo := newTestRepo(t)
+	remote := t.TempDir()
+	gitCmd(t, remote, "init", "--bare", "--initial-branch=main")
+	gitCmd(t, repo, "remote", "add", "origin", remote)
+	gitCmd(t, repo, "push", "origin", "HEAD:refs/heads/remote-branch")
+
+	opts := FetchOptions{Policy: FetchIfStale, MaxAge: time.Hour, Timeout: 10 * time.Second}
+	if err := EnsureBranch(repo, "remote-branch", opts); err != nil {
+		t.Fatalf("expected branch tracking, got %v", err)
+	}
+	upstream := gitCmd(t, repo, "rev-parse", "--abbrev-ref", "remote-branch@{upstream}")
+	if upstream != "origin/remote-branch" {
+		t.Fatalf("expected upstream origin/remote-branch, got %q", upstream)
+	}
+	if _, ok := lastFetch(repo, "origin", "remote-branch"); !ok {
+		t.Fatalf("expected fetch time to be recorded")
+	}
+}
+
+func TestEnsureBranchIgnoresUnreadableFetchTimes(t *testing.T) {
+	repo := newTestRepo(t)
+	remote := t.TempDir()
+	gitCmd(t, remote, "init", "--bare", "--initial-branch=main")
+	gitCmd(t, repo, "remote", "add", "origin", remote)
+	gitCmd(t, repo, "push", "origin", "HEAD:refs/heads/remote-branch")
+	path, err := state.Path(fetchStateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := FetchOptions{Policy: FetchAlways, Timeout: 10 * time.Second}
+	if err := EnsureBranch(repo, "remote-branch", opts); err != nil {
+		t.Fatalf("expected the fetched branch to be created, got %v", err)
+	}
+}
+
+func TestEnsureBranchFallsBackWhenOffline(t *testing.T) {
+	repo := newTestRepo(t)
+	remote := t.TempDir()
+	gitCmd(t, remote, "init", "--bare", "--initial-branch=main")
+	gitCmd(t, repo, "remote", "add", "origin", remote)
+	gitCmd(t, repo, "push", "origin", "HEAD:refs/heads/remote-branch")
+	gitCmd(t, repo, "fetch", "origin")
+	// The remote becomes unreachable, but its remote-tracking ref is still there.
+	gitCmd(t, repo, "remote", "set-url", "origin", filepath.Join(t.TempDir(), "missing"))
+
+	var warnings []error
+	opts := FetchOptions{Policy: FetchIfStale, Timeout: 10 * time.Second, Warn: func(err error) { warnings = append(warnings, err) }}
+	if err := EnsureBranch(repo, "remote-branch", opts); err != nil {
+		t.Fatalf("expected the existing remote-tracking ref to be used, got %v", err)
+	}
+	if upstream := gitCmd(t, repo, "rev-parse", "--abbrev-ref", "remote-branch@{upstream}"); upstream != "origin/remote-branch" {
+		t.Fatalf("expected upstream origin/remote-branch, got %q", upstream)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("expected a warning about the failed fetch, got %v", warnings)
+	}
+
+	// Without a ref to fall back on, the failure stands.
+	if err := EnsureBranch(repo, "other", opts); err == nil {
+		t.Fatal("expected the failed fetch of an unknown branch to fail")
+	}
+	// FetchAlways asks for fresh data, so it does not fall back.
+	gitCmd(t, repo, "branch", "-D", "remote-branch")
+	opts.Policy = FetchAlways
+	if err := EnsureBranch(repo, "remote-branch", opts); err == nil {
+		t.Fatal("expected FetchAlways to fail when the fetch fails")
+	}
+}
+
+func TestBranches(t *testing.T) {
+	repo := newTestRepo(t)
+	gitCmd(t, repo, "branch", "feature/x")
+
+	branches, err := Branches(repo)
+	if err != nil {
+		t.Fatalf("expected branches, got %v", err)
+	}
+	if len(branches) != 2 || branches[0] != "feature/x" || branches[1] != "main" {
+		t.Fatalf("expected [feature/x main], got %q", branches)
+	}
+}
+
+func TestSupportsWorktrees(t *testing.T) {
+	for version, want := range map[string]bool{
+		"2.30.9":                 false,
+		"2.31":                   true,
+		"2.39.3 (Apple Git-146)": true,
+		"2.45.1.windows.1":       true,
+		"3.0":                    true,
+		"1.9.5":                  false,
+	} {
+		if got := SupportsWorktrees(version); got != want {
+			t.Errorf("SupportsWorktrees(%q) = %t, want %t", version, got, want)
+		}
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	repo := newTestRepo(t)
+
+	if _, err := RepositoryRoot(t.TempDir()); !errors.Is(err, ErrNotRepository) {
+		t.Fatalf("expected ErrNotRepository, got %v", err)
+	}
+	if _, err := hasLocalBranch(t.TempDir(), "main"); !errors.Is(err, ErrNotRepository) {
+		t.Fatalf("expected branch check outside a repo to fail with ErrNotRepository, got %v", err)
+	}
+	if ok, err := hasLocalBranch(repo, "missing"); err != nil || ok {
+		t.Fatalf("expected missing branch without error, got %v, %v", ok, err)
+	}
+
+	_, err := runGit(repo, "rev-parse", "--verify", "missing^{commit}")
+	gitErr, ok := errors.AsType[*Error](err)
+	if !ok {
+		t.Fatalf("expected *Error, got %T", err)
+	}
+	if gitErr.ExitCode != 128 || !errors.Is(err, ErrRefNotFound) {
+		t.Fatalf("expected exit code 128 classified as ErrRefNotFound, got %d: %v", gitErr.ExitCode, err)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	if _, err := runGit(repo, "status"); !errors.Is(err, ErrGitNotInstalled) {
+		t.Fatalf("expected ErrGitNotInstalled, got %v", err)
+	}
+}
+
+// newTestRepo creates a repository with a single commit and an isolated state directory.
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	t.Setenv("TREEMUX_STATE_DIR", t.TempDir())
+	t.Setenv("GIT_AUTHOR_NAME", "treemux")
+	t.Setenv("GIT_AUTHOR_EMAIL", "treemux@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "treemux")
+	t.Setenv("GIT_COMMITTER_EMAIL", "treemux@example.com")
+	dir := t.TempDir()
+	gitCmd(t, dir, "init", "--initial-branch=main")
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("test"), 0o644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	gitCmd(t, dir, "add", ".")
+	gitCmd(t, dir, "commit", "-m", "init")
+	return dir
+}
+
+// gitCmd runs git in dir and fails the test on error.
+func gitCmd(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	output, err := runGit(dir, args...)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	return output
+}
@@ -0,0 +1,171 @@
+// Package history keeps a per-terminal jump list of the sessions treemux attached to.
+//
+// Jump lists are keyed by the tty of the tmux client, which is the terminal it runs in, and are
+// persisted in the state directory. A terminal that reattaches keeps its list, even across tmux
+// server restarts, but a list follows the tty rather than the terminal: when the tty is closed and
+// the system hands it to a new terminal, that terminal inherits the list.
+package history
+
+import (
+	"slices"
+
+	"github.com/ian-howell/treemux/internal/state"
+)
+
+// stateFile is the state file holding every client's jump list.
+const stateFile = "history.json"
+
+// maxEntries bounds the length of a single jump list.
+const maxEntries = 100
+
+// defaultClient keys the jump list used when the client cannot be identified.
+const defaultClient = "default"
+
+// JumpList is an ordered history of sessions with a cursor, like a browser's back/forward list.
+type JumpList struct {
+	// Entries are session names, oldest first.
+	Entries []string `json:"entries"`
+
+	// Index is the position of the current session in Entries.
+	Index int `json:"index"`
+}
+
+// Push records session as the current entry. Entries after the cursor are discarded, and pushing
+// the current entry again is a no-op.
+func (j *JumpList) Push(session string) {
+	if session == "" {
+		return
+	}
+	if current, ok := j.Current(); ok && current == session {
+		return
+	}
+	if len(j.Entries) > 0 {
+		j.Entries = j.Entries[:j.Index+1]
+	}
+	j.Entries = append(j.Entries, session)
+	if len(j.Entries) > maxEntries {
+		j.Entries = slices.Clone(j.Entries[len(j.Entries)-maxEntries:])
+	}
+	j.Index = len(j.Entries) - 1
+}
+
+// Current returns the entry under the cursor.
+func (j *JumpList) Current() (string, bool) {
+	if j.Index < 0 || j.Index >= len(j.Entries) {
+		return "", false
+	}
+	return j.Entries[j.Index], true
+}
+
+// Back moves the cursor to the closest earlier entry for which alive returns true.
+func (j *JumpList) Back(alive func(string) bool) (string, bool) {
+	return j.move(-1, alive)
+}
+
+// Forward moves the cursor to the closest later entry for which alive returns true.
+func (j *JumpList) Forward(alive func(string) bool) (string, bool) {
+	return j.move(1, alive)
+}
+
+// Last returns the most recent entry, other than current, for which alive returns true, and
+// pushes it. Calling Last repeatedly toggles between two sessions.
+func (j *JumpList) Last(current string, alive func(string) bool) (string, bool) {
+	for i := len(j.Entries) - 1; i >= 0; i-- {
+		if name := j.Entries[i]; name != current && alive(name) {
+			j.Push(current)
+			j.Push(name)
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Remove drops every entry for session, e.g. because it was closed. The cursor stays on the same
+// entry, or moves to the previous one if the current entry was removed.
+func (j *JumpList) Remove(session string) {
+	entries := make([]string, 0, len(j.Entries))
+	index := -1
+	for i, name := range j.Entries {
+		// Removing an entry may leave two equal entries next to each other, which Push avoids.
+		if name != session && (len(entries) == 0 || entries[len(entries)-1] != name) {
+			entries = append(entries, name)
+		}
+		if i == j.Index {
+			index = len(entries) - 1
+		}
+	}
+	j.Entries = entries
+	j.Index = max(index, 0)
+}
+
+// move steps the cursor by step until it lands on an entry for which alive returns true. The
+// cursor does not move if there is no such entry.
+func (j *JumpList) move(step int, alive func(string) bool) (string, bool) {
+	current, _ := j.Current()
+	for i := j.Index + step; i >= 0 && i < len(j.Entries); i += step {
+		if name := j.Entries[i]; name != current && alive(name) {
+			j.Index = i
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Store holds the jump lists of every client.
+type Store struct {
+	// Clients maps a client identifier, normally its tty, to its jump list.
+	Clients map[string]*JumpList `json:"clients"`
+}
+
+// Load reads the persisted jump lists.
+func Load() (*Store, error) {
+	store := &Store{}
+	if err := state.ReadJSON(stateFile, store); err != nil {
+		return nil, err
+	}
+	if store.Clients == nil {
+		store.Clients = map[string]*JumpList{}
+	}
+	return store, nil
+}
+
+// Save persists the jump lists.
+func (s *Store) Save() error {
+	return state.WriteJSON(stateFile, s)
+}
+
+// For returns the jump list of client, creating it if needed.
+func (s *Store) For(client string) *JumpList {
+	if client == "" {
+		client = defaultClient
+	}
+	list, ok := s.Clients[client]
+	if !ok {
+		list = &JumpList{}
+		s.Clients[client] = list
+	}
+	return list
+}
+
+// Forget removes session from every client's jump list.
+func (s *Store) Forget(session string) {
+	for _, list := range s.Clients {
+		list.Remove(session)
+	}
+}
+
+// Recorder records attaches made by a single client.
+type Recorder struct {
+	// Client identifies the tmux client by its tty, so that the jump list belongs to the terminal.
+	Client string
+}
+
+// Record pushes session onto the client's jump list and persists it.
+func (r Recorder) Record(session string) error {
+	store, err := Load()
+	if err != nil {
+		return err
+	}
+	store.For(r.Client).Push(session)
+	return store.Save()
+}
@@ -0,0 +1,72 @@
+package history
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestJumpList(t *testing.T) {
+	alive := func(name string) bool { return name != "dead" }
+	list := &JumpList{}
+	for _, name := range []string{"a", "b", "b", "dead", "c"} {
+		list.Push(name)
+	}
+	if want := []string{"a", "b", "dead", "c"}; !slices.Equal(list.Entries, want) {
+		t.Fatalf("expected entries %v, got %v", want, list.Entries)
+	}
+
+	if name, ok := list.Back(alive); !ok || name != "b" {
+		t.Fatalf("expected back to skip dead session and reach b, got %q, %v", name, ok)
+	}
+	if name, ok := list.Back(alive); !ok || name != "a" {
+		t.Fatalf("expected back to reach a, got %q, %v", name, ok)
+	}
+	if _, ok := list.Back(alive); ok {
+		t.Fatalf("expected no session before a")
+	}
+	if name, ok := list.Forward(alive); !ok || name != "b" {
+		t.Fatalf("expected forward to reach b, got %q, %v", name, ok)
+	}
+
+	list.Push("d")
+	if want := []string{"a", "b", "d"}; !slices.Equal(list.Entries, want) {
+		t.Fatalf("expected push to discard forward entries, got %v", list.Entries)
+	}
+
+	if name, ok := list.Last("d", alive); !ok || name != "b" {
+		t.Fatalf("expected last to reach b, got %q, %v", name, ok)
+	}
+	if name, ok := list.Last("b", alive); !ok || name != "d" {
+		t.Fatalf("expected last to toggle back to d, got %q, %v", name, ok)
+	}
+}
+
+func TestRecorderPersists(t *testing.T) {
+	t.Setenv("TREEMUX_STATE_DIR", t.TempDir())
+	for _, name := range []string{"a", "b"} {
+		if err := (Recorder{Client: "/dev/pts/1"}).Record(name); err != nil {
+			t.Fatalf("expected record to succeed, got %v", err)
+		}
+	}
+	store, err := Load()
+	if err != nil {
+		t.Fatalf("expected load to succeed, got %v", err)
+	}
+	if current, _ := store.For("/dev/pts/1").Current(); current != "b" {
+		t.Fatalf("expected current session b, got %q", current)
+	}
+	if len(store.For("/dev/pts/2").Entries) != 0 {
+		t.Fatalf("expected other clients to have an empty jump list")
+	}
+}
+
+func TestJumpListRemove(t *testing.T) {
+	list := &JumpList{Entries: []string{"a", "closed", "a", "b", "closed", "c"}, Index: 4}
+	list.Remove("closed")
+	if want := []string{"a", "b", "c"}; !slices.Equal(list.Entries, want) {
+		t.Fatalf("expected entries %v, got %v", want, list.Entries)
+	}
+	if current, _ := list.Current(); current != "b" {
+		t.Fatalf("expected the cursor to move back to b, got %q", current)
+	}
+}
@@ -1,11 +1,17 @@
 package listers
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/ian-howell/treemux/internal/models"
+	"github.com/ian-howell/treemux/internal/tmux"
 	"github.com/ian-howell/treemux/internal/treemux"
 )
 
@@ -14,38 +20,70 @@ type tmuxClient interface {
 	AttachOrSwitch(name string) error
 }
 
+// requirer is implemented by tmux clients that know which capabilities tmux has.
+type requirer interface {
+	Require(capability tmux.Capability) error
+}
+
 type ActiveSessions struct {
 	tmuxClient tmuxClient
+
+	// IncludeWindows also lists each session's windows, nested beneath the session.
+	IncludeWindows bool
 }
 
 func NewActiveSessions(tmuxClient tmuxClient) *ActiveSessions {
 	return &ActiveSessions{tmuxClient: tmuxClient}
 }
 
-// List returns all active sessions.
+// List returns all active sessions. Without a tmux server it returns treemux.ErrTmuxNotRunning.
 func (s *ActiveSessions) List() ([]treemux.Session, error) {
-	args := []string{"list-sessions", "-F", "#{session_name} #{session_last_attached} #{?session_attached,true,false}"}
+	lastAttached := "#{session_last_attached}"
+	if client, ok := s.tmuxClient.(requirer); ok {
+		if err := client.Require(tmux.LastAttached); err != nil {
+			slog.Debug("ranking sessions by when they were created", slog.Any("reason", err))
+			lastAttached = ""
+		}
+	}
+	args := []string{"list-sessions", "-F", "#{session_name}\t#{session_path}\t" + lastAttached + "\t#{?session_attached,true,false}\t#{session_created}"}
 
 	output, err := s.tmuxClient.RunCmd(args)
+	if errors.Is(err, treemux.ErrTmuxNotRunning) {
+		return nil, err
+	}
 	if err != nil {
 		return []treemux.Session{}, nil
 	}
 	lines := strings.Split(strings.TrimSpace(output), "\n")
+	attached, attachedErr := s.attachedSessions()
 
 	sessions := make([]ActiveSession, 0, len(lines))
 	for _, line := range lines {
-		var name string
-		var lastAttachedTime int64
-		var attachedStr string
-		fmt.Sscanf(line, "%s %d %s", &name, &lastAttachedTime, &attachedStr)
-		attached := attachedStr == "true"
+		// Tabs separate the fields because session names and paths may contain spaces.
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			continue
+		}
+		// tmux leaves session_last_attached empty for sessions that were never attached, and it is
+		// not asked for before 2.1, so those rank by when they were created instead.
+		lastAttached := fields[2]
+		if lastAttached == "" {
+			lastAttached = fields[4]
+		}
+		lastAttachedTime, _ := strconv.ParseInt(lastAttached, 10, 64)
+		isAttached := fields[3] == "true"
+		if attachedErr == nil {
+			isAttached = attached[fields[0]]
+		}
 		sessions = append(sessions, ActiveSession{
 			tmuxClient: s.tmuxClient,
 			Session: models.Session{
-				Name:             name,
+				Name:             fields[0],
+				Path:             fields[1],
 				LastAttachedTime: lastAttachedTime,
-				Attached:         attached,
+				Attached:         isAttached,
 			},
+			group: sync.OnceValue(func() []string { return repoGroup(fields[1]) }),
 		})
 	}
 
@@ -53,17 +91,74 @@ func (s *ActiveSessions) List() ([]treemux.Session, error) {
 		return sessions[i].Session.LastAttachedTime > sessions[j].Session.LastAttachedTime
 	})
 
+	var windows map[string][]treemux.Session
+	if s.IncludeWindows {
+		windows = s.listWindows(sessions)
+	}
+
 	treemuxSessions := make([]treemux.Session, 0, len(sessions))
 	for _, session := range sessions {
 		treemuxSessions = append(treemuxSessions, session)
+		treemuxSessions = append(treemuxSessions, windows[session.Session.Name]...)
 	}
 
 	return treemuxSessions, nil
 }
 
+// attachedSessions returns the names of the sessions that a client is attached to. Control-mode
+// clients, such as the one treemux uses to follow changes, do not count.
+func (s *ActiveSessions) attachedSessions() (map[string]bool, error) {
+	output, err := s.tmuxClient.RunCmd([]string{"list-clients", "-F", "#{client_session}\t#{client_control_mode}"})
+	if err != nil {
+		return nil, err
+	}
+	attached := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		session, controlMode, ok := strings.Cut(line, "\t")
+		if ok && controlMode != "1" {
+			attached[session] = true
+		}
+	}
+	return attached, nil
+}
+
+// listWindows returns the windows of every session, keyed by session name.
+func (s *ActiveSessions) listWindows(sessions []ActiveSession) map[string][]treemux.Session {
+	args := []string{"list-windows", "-a", "-F", "#{session_name}\t#{window_index}\t#{window_name}\t#{pane_current_path}"}
+	output, err := s.tmuxClient.RunCmd(args)
+	if err != nil {
+		return nil
+	}
+	parents := make(map[string]ActiveSession, len(sessions))
+	for _, session := range sessions {
+		parents[session.Session.Name] = session
+	}
+	windows := map[string][]treemux.Session{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 {
+			continue
+		}
+		parent, ok := parents[fields[0]]
+		if !ok {
+			continue
+		}
+		windows[fields[0]] = append(windows[fields[0]], ActiveWindow{
+			parent: parent,
+			Index:  fields[1],
+			Title:  fields[2],
+			Dir:    fields[3],
+		})
+	}
+	return windows
+}
+
 type ActiveSession struct {
 	tmuxClient tmuxClient
 	Session    models.Session
+
+	// group lazily computes the session's repository grouping, since it costs a git invocation.
+	group func() []string
 }
 
 // Attach attaches to the session, creating it if it doesn't exist.
@@ -71,6 +166,24 @@ func (a ActiveSession) Attach() error {
 	return a.tmuxClient.AttachOrSwitch(a.Session.Name)
 }
 
+// Name returns the tmux session name.
+func (a ActiveSession) Name() string {
+	return a.Session.Name
+}
+
+// Path returns the session's working directory.
+func (a ActiveSession) Path() string {
+	return a.Session.Path
+}
+
+// Group returns the repository and worktree containing the session's directory.
+func (a ActiveSession) Group() []string {
+	if a.group == nil {
+		return nil
+	}
+	return a.group()
+}
+
 // String returns the session as a string for display in a prompter.
 func (a ActiveSession) String() string {
 	if a.Session.Attached {
@@ -78,3 +191,47 @@ func (a ActiveSession) String() string {
 	}
 	return fmt.Sprintf("  %s", a.Session.Name)
 }
+
+// ActiveWindow is a window of an active session. It is nested beneath its session in a tree.
+type ActiveWindow struct {
+	parent ActiveSession
+
+	// Index is the window index within its session.
+	Index string
+
+	// Title is the window name.
+	Title string
+
+	// Dir is the current directory of the window's active pane.
+	Dir string
+}
+
+// Attach switches to the window, attaching to its session if needed.
+func (w ActiveWindow) Attach() error {
+	return w.parent.tmuxClient.AttachOrSwitch(w.Name())
+}
+
+// Name returns the window's tmux target, e.g. "work:1".
+func (w ActiveWindow) Name() string {
+	return w.parent.Session.Name + ":" + w.Index
+}
+
+// Owner returns the name of the window's session.
+func (w ActiveWindow) Owner() string {
+	return w.parent.Session.Name
+}
+
+// Path returns the current directory of the window's active pane.
+func (w ActiveWindow) Path() string {
+	return w.Dir
+}
+
+// Group nests the window beneath its session.
+func (w ActiveWindow) Group() []string {
+	return append(slices.Clone(w.parent.Group()), w.parent.Session.Name)
+}
+
+// String returns the window as a string for display in a prompter.
+func (w ActiveWindow) String() string {
+	return fmt.Sprintf("    %s: %s", w.Index, w.Title)
+}
@@ -0,0 +1,111 @@
+package listers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ian-howell/treemux/internal/tmux"
+	"github.com/ian-howell/treemux/internal/tmuxfake"
+	"github.com/ian-howell/treemux/internal/tmuxtest"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+func TestActiveSessions(t *testing.T) {
+	server := tmuxtest.NewServer(t)
+	dir := t.TempDir()
+	spaced := filepath.Join(dir, "with space")
+	if err := os.Mkdir(spaced, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	server.NewSession("work", dir)
+	server.NewSession("my notes", spaced)
+	server.NewWindow("work", "logs", spaced)
+	// The most recently attached session is listed first. Control clients, like the one treemux
+	// uses to follow changes, attach without counting as attached.
+	server.AttachClient("my notes")
+
+	lister := NewActiveSessions(server.Client())
+	sessions, err := lister.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names, paths []string
+	for _, session := range sessions {
+		names = append(names, session.Name())
+		paths = append(paths, session.Path())
+	}
+	if !slices.Equal(names, []string{"my notes", "work"}) || !slices.Equal(paths, []string{spaced, dir}) {
+		t.Fatalf("expected my notes and work with their paths, got %v at %v", names, paths)
+	}
+	for _, session := range sessions {
+		if session.(ActiveSession).Session.Attached {
+			t.Fatalf("expected %q not to count as attached", session.Name())
+		}
+	}
+
+	lister.IncludeWindows = true
+	sessions, err = lister.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names = names[:0]
+	for _, session := range sessions {
+		names = append(names, session.Name())
+	}
+	if want := []string{"my notes", "my notes:0", "work", "work:0", "work:1"}; !slices.Equal(names, want) {
+		t.Fatalf("expected %v, got %v", want, names)
+	}
+	if window := sessions[4]; window.Path() != spaced || !slices.Equal(window.Group(), []string{"work"}) {
+		t.Fatalf("expected the logs window nested in work at %q, got %q in %v", spaced, window.Path(), window.Group())
+	}
+}
+
+func TestActiveSessionsOldTmux(t *testing.T) {
+	tests := []struct {
+		version string
+		want    []string
+	}{
+		{version: "3.4", want: []string{"used", "created"}},
+		// Before session_last_attached, sessions rank by when they were created.
+		{version: "2.0", want: []string{"created", "used"}},
+	}
+	for _, test := range tests {
+		t.Run(test.version, func(t *testing.T) {
+			server := &tmuxfake.Server{Version: test.version}
+			used := server.AddSession("used", "/used")
+			used.Created, used.LastAttached = 10, 100
+			server.AddSession("created", "/created").Created = 20
+			sessions, err := NewActiveSessions(tmux.New(tmux.WithRunner(server))).List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, session := range sessions {
+				names = append(names, session.Name())
+			}
+			if !slices.Equal(names, test.want) {
+				t.Fatalf("expected %v, got %v", test.want, names)
+			}
+		})
+	}
+}
+
+func TestActiveSessionsEmptyServer(t *testing.T) {
+	server := tmuxtest.NewServer(t)
+	sessions, err := NewActiveSessions(server.Client()).List()
+	if err != nil || len(sessions) != 0 {
+		t.Fatalf("expected no sessions without error, got %v, %v", sessions, err)
+	}
+}
+
+func TestActiveSessionsNoServer(t *testing.T) {
+	server := tmuxtest.NewServer(t)
+	client := server.Client()
+	server.Run("kill-server")
+	if _, err := NewActiveSessions(client).List(); !errors.Is(err, treemux.ErrTmuxNotRunning) {
+		t.Fatalf("expected ErrTmuxNotRunning, got %v", err)
+	}
+}
@@ -0,0 +1,302 @@
+package listers
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ian-howell/treemux/internal/state"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// cacheVersion is bumped whenever the cache file layout changes, invalidating older caches.
+const cacheVersion = 1
+
+// Cached decorates a lister with a persistent cache of its last results.
+//
+// List returns cached results immediately when there are any. If they are older than the TTL, the
+// wrapped lister is refreshed in the background and the fresh results are delivered through
+// Updates. Cached sessions always attach through the fresh result for the same session, so a
+// stale entry can never attach to something that no longer exists.
+type Cached struct {
+	lister treemux.Lister
+	key    string
+	ttl    time.Duration
+
+	// ReadOnly serves and refreshes cached results without writing them back, as for a dry run.
+	ReadOnly bool
+
+	// refreshMu guards fresh, the wrapped lister's results once it has been listed successfully.
+	refreshMu sync.Mutex
+	fresh     []treemux.Session
+	refreshed bool
+
+	// updates delivers the background refresh, if one was started.
+	updates chan []treemux.Session
+}
+
+// NewCached wraps lister with a cache. The key identifies the lister and its configuration, so
+// listers configured differently never share cached results.
+func NewCached(lister treemux.Lister, key string, ttl time.Duration) *Cached {
+	return &Cached{
+		lister: lister,
+		key:    key,
+		ttl:    ttl,
+	}
+}
+
+// String describes the lister for logs.
+func (c *Cached) String() string {
+	return "cached " + treemux.ListerName(c.lister)
+}
+
+// cacheFile is the on-disk layout of a lister's cache.
+type cacheFile struct {
+	Version  int            `json:"version"`
+	Key      string         `json:"key"`
+	Updated  time.Time      `json:"updated"`
+	Sessions []cacheSession `json:"sessions"`
+}
+
+// cacheSession is the cached form of a session.
+type cacheSession struct {
+	Name  string   `json:"name"`
+	Path  string   `json:"path,omitempty"`
+	Group []string `json:"group,omitempty"`
+	Label string   `json:"label"`
+
+	// Owner names the tmux session of a nested session, such as a window.
+	Owner string `json:"owner,omitempty"`
+}
+
+// List returns the cached sessions, or lists the wrapped lister when nothing usable is cached.
+func (c *Cached) List() ([]treemux.Session, error) {
+	cache, ok := c.load()
+	if !ok {
+		return c.refresh()
+	}
+	if time.Since(cache.Updated) > c.ttl {
+		updates := make(chan []treemux.Session, 1)
+		c.updates = updates
+		go func() {
+			defer close(updates)
+			if sessions, err := c.refresh(); err == nil {
+				updates <- sessions
+			}
+		}()
+	}
+
+	sessions := make([]treemux.Session, 0, len(cache.Sessions))
+	for _, entry := range cache.Sessions {
+		// Drop entries whose directory has disappeared since they were cached.
+		if entry.Path != "" {
+			if _, err := os.Stat(entry.Path); err != nil {
+				continue
+			}
+		}
+		sessions = append(sessions, CachedSession{cache: c, entry: entry})
+	}
+	return sessions, nil
+}
+
+// Updates delivers the results of the background refresh started by List, if any, followed by
+// the updates of the wrapped lister if it is a Watcher. Those are cached as well.
+func (c *Cached) Updates() <-chan []treemux.Session {
+	var watched <-chan []treemux.Session
+	if watcher, ok := c.lister.(treemux.Watcher); ok {
+		watched = watcher.Updates()
+	}
+	switch {
+	case watched == nil && c.updates == nil:
+		return nil
+	case watched == nil:
+		return c.updates
+	}
+	updates := make(chan []treemux.Session)
+	go func() {
+		defer close(updates)
+		if c.updates != nil {
+			for sessions := range c.updates {
+				updates <- sessions
+			}
+		}
+		for sessions := range watched {
+			c.storeSessions(sessions)
+			updates <- sessions
+		}
+	}()
+	return updates
+}
+
+// refresh lists the wrapped lister at most once per run and stores the results in the cache. A
+// failed listing is not remembered, so the next call tries again.
+func (c *Cached) refresh() ([]treemux.Session, error) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	if c.refreshed {
+		return c.fresh, nil
+	}
+	sessions, err := c.listAndStore()
+	if err != nil {
+		return nil, err
+	}
+	c.fresh, c.refreshed = sessions, true
+	return sessions, nil
+}
+
+// listAndStore lists the wrapped lister and stores the results in the cache.
+func (c *Cached) listAndStore() ([]treemux.Session, error) {
+	sessions, err := c.lister.List()
+	if err != nil {
+		return nil, err
+	}
+	c.storeSessions(sessions)
+	return sessions, nil
+}
+
+// storeSessions replaces the cached sessions.
+func (c *Cached) storeSessions(sessions []treemux.Session) {
+	if c.ReadOnly {
+		return
+	}
+	cache := cacheFile{
+		Version:  cacheVersion,
+		Key:      c.key,
+		Updated:  time.Now(),
+		Sessions: make([]cacheSession, 0, len(sessions)),
+	}
+	for _, session := range sessions {
+		entry := cacheSession{
+			Name:  session.Name(),
+			Path:  session.Path(),
+			Group: session.Group(),
+			Label: session.String(),
+		}
+		if nested, ok := session.(treemux.Nested); ok {
+			entry.Owner = nested.Owner()
+		}
+		cache.Sessions = append(cache.Sessions, entry)
+	}
+	// The cache only speeds up the next run, so failing to write it is not an error.
+	_ = c.store(cache)
+}
+
+// load reads the cache, reporting false if there is none or it cannot be used.
+func (c *Cached) load() (cacheFile, bool) {
+	path, err := c.path()
+	if err != nil {
+		return cacheFile{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cacheFile{}, false
+	}
+	var cache cacheFile
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return cacheFile{}, false
+	}
+	if cache.Version != cacheVersion || cache.Key != c.key {
+		return cacheFile{}, false
+	}
+	return cache, true
+}
+
+// store writes the cache atomically.
+func (c *Cached) store(cache cacheFile) error {
+	path, err := c.path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return state.WriteJSONFile(path, cache)
+}
+
+// path returns the cache file of this lister.
+func (c *Cached) path() (string, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(c.key))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// CacheDir returns the directory holding lister caches: $TREEMUX_CACHE_DIR, or else treemux/listers
+// in the user cache directory.
+func CacheDir() (string, error) {
+	if dir := os.Getenv("TREEMUX_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(base, "treemux", "listers"), nil
+}
+
+// ClearCache removes every cached lister result.
+func ClearCache() error {
+	dir, err := CacheDir()
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to clear cache: %w", err)
+	}
+	return nil
+}
+
+// CachedSession is a session restored from the cache.
+type CachedSession struct {
+	cache *Cached
+	entry cacheSession
+}
+
+// Attach attaches through the fresh session with the same name and path.
+func (s CachedSession) Attach() error {
+	sessions, err := s.cache.refresh()
+	if err != nil {
+		return fmt.Errorf("failed to refresh cached session %q: %w", s.entry.Name, err)
+	}
+	for _, session := range sessions {
+		if session.Name() == s.entry.Name && session.Path() == s.entry.Path {
+			return session.Attach()
+		}
+	}
+	return fmt.Errorf("session %q no longer exists", s.entry.Name)
+}
+
+// Name returns the cached session name.
+func (s CachedSession) Name() string {
+	return s.entry.Name
+}
+
+// Path returns the cached session directory.
+func (s CachedSession) Path() string {
+	return s.entry.Path
+}
+
+// Group returns the cached session group.
+func (s CachedSession) Group() []string {
+	return s.entry.Group
+}
+
+// String returns the cached display label.
+func (s CachedSession) String() string {
+	return s.entry.Label
+}
+
+// Owner returns the name of the tmux session the cached session belongs to.
+func (s CachedSession) Owner() string {
+	return cmp.Or(s.entry.Owner, s.entry.Name)
+}
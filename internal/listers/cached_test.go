@@ -0,0 +1,223 @@
+package listers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ian-howell/treemux/internal/tmuxfake"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// stubLister returns fixed sessions, or err if set, and counts how often it is listed.
+type stubLister struct {
+	sessions []treemux.Session
+	err      error
+	calls    int
+}
+
+func (l *stubLister) List() ([]treemux.Session, error) {
+	l.calls++
+	if l.err != nil {
+		return nil, l.err
+	}
+	return l.sessions, nil
+}
+
+// stubSession is a Session that records whether it was attached.
+type stubSession struct {
+	name     string
+	path     string
+	attached *bool
+}
+
+func (s stubSession) Attach() error   { *s.attached = true; return nil }
+func (s stubSession) String() string  { return s.name }
+func (s stubSession) Name() string    { return s.name }
+func (s stubSession) Path() string    { return s.path }
+func (s stubSession) Group() []string { return nil }
+
+// pickPrompter picks the session with the given name.
+type pickPrompter string
+
+func (p pickPrompter) Prompt(sessions []treemux.Session, _ int, _ <-chan []treemux.Session) (treemux.Session, error) {
+	for _, session := range sessions {
+		if session.Name() == string(p) {
+			return session, nil
+		}
+	}
+	return nil, nil
+}
+
+// recordingHistory collects the recorded sessions.
+type recordingHistory []string
+
+func (h *recordingHistory) Record(session string) error {
+	*h = append(*h, session)
+	return nil
+}
+
+func TestCachedStaleWhileRevalidate(t *testing.T) {
+	t.Setenv("TREEMUX_CACHE_DIR", t.TempDir())
+	kept, removed := t.TempDir(), filepath.Join(t.TempDir(), "removed")
+	if err := os.Mkdir(removed, 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	var attached bool
+	lister := &stubLister{sessions: []treemux.Session{
+		stubSession{name: "kept", path: kept, attached: &attached},
+		stubSession{name: "removed", path: removed, attached: &attached},
+	}}
+
+	// A cold cache lists synchronously and has no updates.
+	cold := NewCached(lister, "stub", time.Hour)
+	if sessions, err := cold.List(); err != nil || len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions from a cold cache, got %d, %v", len(sessions), err)
+	}
+	if cold.Updates() != nil {
+		t.Fatalf("expected no updates from a cold cache")
+	}
+
+	// A fresh cache is served without listing, and entries whose directory vanished are dropped.
+	if err := os.Remove(removed); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	fresh := NewCached(lister, "stub", time.Hour)
+	sessions, err := fresh.List()
+	if err != nil || len(sessions) != 1 || sessions[0].Name() != "kept" {
+		t.Fatalf("expected only the kept session, got %v, %v", sessions, err)
+	}
+	if lister.calls != 1 || fresh.Updates() != nil {
+		t.Fatalf("expected a fresh cache to skip listing, got %d calls", lister.calls)
+	}
+
+	// Attaching a cached session goes through the live session.
+	if err := sessions[0].Attach(); err != nil || !attached {
+		t.Fatalf("expected the live session to be attached, got %v", err)
+	}
+
+	// A stale cache is served immediately and refreshed in the background.
+	stale := NewCached(lister, "stub", 0)
+	if sessions, err := stale.List(); err != nil || len(sessions) != 1 {
+		t.Fatalf("expected cached sessions from a stale cache, got %v, %v", sessions, err)
+	}
+	select {
+	case update := <-stale.Updates():
+		if len(update) != 2 {
+			t.Fatalf("expected the refreshed sessions, got %v", update)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected a background refresh")
+	}
+
+	if err := ClearCache(); err != nil {
+		t.Fatalf("expected cache to clear, got %v", err)
+	}
+	if _, ok := NewCached(lister, "stub", time.Hour).load(); ok {
+		t.Fatalf("expected no cache after clearing")
+	}
+}
+
+func TestCachedRetriesFailedRefresh(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TREEMUX_CACHE_DIR", t.TempDir())
+	var attached bool
+	lister := &stubLister{sessions: []treemux.Session{stubSession{name: "work", path: dir, attached: &attached}}}
+	if _, err := NewCached(lister, "stub", time.Hour).List(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The background refresh of a stale cache fails, say because tmux is briefly unreachable.
+	lister.err = errors.New("unreachable")
+	stale := NewCached(lister, "stub", 0)
+	sessions, err := stale.List()
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("expected the cached session, got %v, %v", sessions, err)
+	}
+	for range stale.Updates() {
+		t.Fatal("expected no update from a failed refresh")
+	}
+
+	// Attaching lists again rather than repeating the failure.
+	lister.err = nil
+	if err := sessions[0].Attach(); err != nil || !attached {
+		t.Fatalf("expected the live session to be attached, got %v", err)
+	}
+}
+
+func TestCachedConcurrentStores(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TREEMUX_CACHE_DIR", dir)
+	cached := NewCached(&stubLister{}, "stub", time.Hour)
+
+	// Pickers and the daemon refresh the same cache at the same time.
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Go(func() {
+			sessions := make([]cacheSession, i+1)
+			for j := range sessions {
+				sessions[j] = cacheSession{Name: strings.Repeat("s", 1000), Label: "label"}
+			}
+			if err := cached.store(cacheFile{Version: cacheVersion, Key: "stub", Sessions: sessions}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if _, ok := cached.load(); !ok {
+		t.Fatal("expected a readable cache after concurrent stores")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("expected only the cache file, got %v", entries)
+	}
+}
+
+func TestCachedReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TREEMUX_CACHE_DIR", dir)
+	var attached bool
+	lister := &stubLister{sessions: []treemux.Session{stubSession{name: "work", path: dir, attached: &attached}}}
+
+	cached := NewCached(lister, "stub", time.Hour)
+	cached.ReadOnly = true
+	if sessions, err := cached.List(); err != nil || len(sessions) != 1 {
+		t.Fatalf("expected the listed session, got %d, %v", len(sessions), err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected nothing written, got %v", entries)
+	}
+}
+
+func TestCachedWindowOwner(t *testing.T) {
+	t.Setenv("TREEMUX_CACHE_DIR", t.TempDir())
+	server := &tmuxfake.Server{}
+	server.AddSession("work", t.TempDir(), "edit", "logs")
+	active := NewActiveSessions(server)
+	active.IncludeWindows = true
+
+	// The first run fills the cache and the second picks the window from it. Both record the
+	// window's session, as the jump list only knows sessions.
+	var history recordingHistory
+	for range 2 {
+		app, err := treemux.New(
+			treemux.WithListers([]treemux.Lister{NewCached(active, "windows", time.Hour)}),
+			treemux.WithPrompter(pickPrompter("work:1")),
+			treemux.WithHistory(&history),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := app.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := (recordingHistory{"work", "work"}); !slices.Equal(history, want) {
+		t.Fatalf("expected %v, got %v", want, history)
+	}
+}
@@ -0,0 +1,42 @@
+package listers
+
+import (
+	"github.com/ian-howell/treemux/internal/executor"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// Counted decorates a lister that runs its commands with an executor of its own, forked from the
+// default one, so that its commands are counted apart from those of other listers, including ones
+// refreshing in the background at the same time.
+type Counted struct {
+	lister   treemux.Lister
+	executor *executor.Executor
+}
+
+// NewCounted wraps lister, whose commands run with e.
+func NewCounted(lister treemux.Lister, e *executor.Executor) *Counted {
+	return &Counted{lister: lister, executor: e}
+}
+
+// List lists the wrapped lister.
+func (c *Counted) List() ([]treemux.Session, error) {
+	return c.lister.List()
+}
+
+// String describes the wrapped lister for logs.
+func (c *Counted) String() string {
+	return treemux.ListerName(c.lister)
+}
+
+// Updates passes on the updates of the wrapped lister, if it has any.
+func (c *Counted) Updates() <-chan []treemux.Session {
+	if watcher, ok := c.lister.(treemux.Watcher); ok {
+		return watcher.Updates()
+	}
+	return nil
+}
+
+// Stats returns the commands the wrapped lister has run so far.
+func (c *Counted) Stats() executor.Stats {
+	return c.executor.Stats()
+}
@@ -0,0 +1,94 @@
+package listers
+
+import (
+	"log/slog"
+
+	"cmp"
+
+	"github.com/ian-howell/treemux/internal/daemon"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// daemonClient is the subset of the daemon client used by the Daemon lister.
+type daemonClient interface {
+	List() ([]daemon.Session, error)
+	Attach(session, client string) error
+}
+
+// attacher attaches directly through tmux and identifies the current client.
+type attacher interface {
+	AttachOrSwitch(name string) error
+	ClientTTY() string
+}
+
+// Daemon lists sessions from a running treemux daemon instead of querying tmux directly.
+type Daemon struct {
+	daemonClient daemonClient
+	tmuxClient   attacher
+
+	// Fallback, if set, is listed instead when the daemon cannot be reached, e.g. because it
+	// exited after treemux connected to it.
+	Fallback treemux.Lister
+}
+
+// NewDaemon returns a lister backed by the daemon client. The tmux client is used to attach when
+// treemux is not running inside tmux, since only then there is no client for the daemon to switch.
+func NewDaemon(daemonClient daemonClient, tmuxClient attacher) *Daemon {
+	return &Daemon{daemonClient: daemonClient, tmuxClient: tmuxClient}
+}
+
+// List returns the daemon's session index, or lists the fallback if the daemon fails to answer.
+func (d *Daemon) List() ([]treemux.Session, error) {
+	indexed, err := d.daemonClient.List()
+	if err != nil && d.Fallback != nil {
+		slog.Debug("daemon unavailable; listing directly", slog.Any("error", err))
+		return d.Fallback.List()
+	}
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]treemux.Session, 0, len(indexed))
+	for _, session := range indexed {
+		sessions = append(sessions, DaemonSession{lister: d, session: session})
+	}
+	return sessions, nil
+}
+
+// DaemonSession is a session from the daemon's index.
+type DaemonSession struct {
+	lister  *Daemon
+	session daemon.Session
+}
+
+// Attach asks the daemon to switch the current client, or attaches directly outside tmux.
+func (s DaemonSession) Attach() error {
+	if client := s.lister.tmuxClient.ClientTTY(); client != "" {
+		return s.lister.daemonClient.Attach(s.session.Name, client)
+	}
+	return s.lister.tmuxClient.AttachOrSwitch(s.session.Name)
+}
+
+// Name returns the tmux session name.
+func (s DaemonSession) Name() string {
+	return s.session.Name
+}
+
+// Path returns the session's working directory.
+func (s DaemonSession) Path() string {
+	return s.session.Path
+}
+
+// Group returns the group resolved by the daemon.
+func (s DaemonSession) Group() []string {
+	return s.session.Group
+}
+
+// String returns the label rendered by the daemon.
+func (s DaemonSession) String() string {
+	return s.session.Label
+}
+
+// Owner returns the name of the tmux session the indexed session belongs to.
+func (s DaemonSession) Owner() string {
+	return cmp.Or(s.session.Owner, s.session.Name)
+}
@@ -0,0 +1,289 @@
+package listers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ian-howell/treemux/internal/executor"
+	"github.com/ian-howell/treemux/internal/snapshot"
+	"github.com/ian-howell/treemux/internal/tmux"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// ExecProtocolVersion is the version of the plugin protocol spoken by Exec. It is passed to
+// plugins in $TREEMUX_PLUGIN_VERSION. Fields may be added without changing it; it is bumped only
+// when a plugin written for the previous version would be misread.
+const ExecProtocolVersion = 1
+
+// execVersionEnv tells plugins which protocol version treemux speaks.
+const execVersionEnv = "TREEMUX_PLUGIN_VERSION"
+
+// DefaultExecTimeout bounds how long an Exec plugin may run.
+const DefaultExecTimeout = 5 * time.Second
+
+// ExecEntry is a session described by a plugin. Plugins print one JSON object per line, e.g.
+//
+//	{"version": 1, "name": "PROJ-123", "path": "/src/api", "group": ["tickets"], "description": "Fix login"}
+//
+// Unknown fields are ignored, so that newer plugins keep working with older treemux.
+type ExecEntry struct {
+	// Version is the protocol version the entry was written for. Zero means ExecProtocolVersion;
+	// entries for a newer version are rejected.
+	Version int `json:"version,omitempty"`
+
+	// Name is the tmux session name. Characters tmux reserves, '.' and ':', are replaced.
+	Name string `json:"name"`
+
+	// Path is the absolute directory the session is created in. Empty uses the current directory.
+	Path string `json:"path,omitempty"`
+
+	// Group nests the session in the tree prompter, outermost first. Empty groups the session
+	// under the repository containing Path.
+	Group []string `json:"group,omitempty"`
+
+	// Description is shown next to the name.
+	Description string `json:"description,omitempty"`
+
+	// Template names a session saved by `treemux save` whose windows and panes the session is
+	// created with, rooted at Path instead of the saved directory.
+	Template string `json:"template,omitempty"`
+
+	// Command is a shell command typed into the session's active pane when it is created.
+	Command string `json:"command,omitempty"`
+}
+
+// validate checks the entry and normalizes its name.
+func (e *ExecEntry) validate() error {
+	if e.Version > ExecProtocolVersion {
+		return fmt.Errorf("protocol version %d is newer than the supported version %d", e.Version, ExecProtocolVersion)
+	}
+	e.Name = tmux.SessionName(e.Name)
+	if e.Name == "" {
+		return errors.New("missing name")
+	}
+	if e.Path != "" && !filepath.IsAbs(e.Path) {
+		return fmt.Errorf("path %q is not absolute", e.Path)
+	}
+	return nil
+}
+
+// Exec lists the sessions described by a plugin: an external command printing ExecEntry objects
+// as JSON lines, e.g. from a ticket tracker or service catalog. Sessions that are already running
+// are left to the active sessions lister.
+type Exec struct {
+	tmuxClient tmuxClient
+
+	// command is the plugin's argv.
+	command []string
+
+	// Timeout bounds each run of the plugin.
+	Timeout time.Duration
+
+	// Restore configures how sessions with a template are rebuilt from it.
+	Restore snapshot.RestoreOptions
+
+	// Executor runs the plugin. Nil uses the default executor.
+	Executor *executor.Executor
+}
+
+// NewExec returns a lister running the plugin command, an argv.
+func NewExec(tmuxClient tmuxClient, command []string) *Exec {
+	return &Exec{tmuxClient: tmuxClient, command: command, Timeout: DefaultExecTimeout}
+}
+
+// String describes the lister for logs.
+func (e *Exec) String() string {
+	return "exec " + strings.Join(e.command, " ")
+}
+
+// List runs the plugin and returns its sessions that are not running.
+func (e *Exec) List() ([]treemux.Session, error) {
+	return e.ListContext(context.Background())
+}
+
+// ListContext is List, killing the plugin when ctx is done.
+func (e *Exec) ListContext(ctx context.Context) ([]treemux.Session, error) {
+	if len(e.command) == 0 {
+		return nil, errors.New("exec lister: no command")
+	}
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.Timeout)
+		defer cancel()
+	}
+	run := executor.Run
+	if e.Executor != nil {
+		run = e.Executor.Run
+	}
+	stdout, stderr, err := run(ctx, executor.Command{
+		Name: e.command[0],
+		Args: e.command[1:],
+		Env:  []string{fmt.Sprintf("%s=%d", execVersionEnv, ExecProtocolVersion)},
+	})
+	if ctxErr := ctx.Err(); errors.Is(ctxErr, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("plugin %q timed out after %s", e.command[0], e.Timeout)
+	} else if ctxErr != nil {
+		return nil, fmt.Errorf("plugin %q: %w", e.command[0], ctxErr)
+	}
+	if err != nil {
+		if stderr = strings.TrimSpace(stderr); stderr != "" {
+			return nil, fmt.Errorf("plugin %q failed: %w: %s", e.command[0], err, stderr)
+		}
+		return nil, fmt.Errorf("plugin %q failed: %w", e.command[0], err)
+	}
+	entries, err := ParseExecEntries(stdout)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %q: %w", e.command[0], err)
+	}
+
+	running := map[string]bool{}
+	if output, err := e.tmuxClient.RunCmd([]string{"list-sessions", "-F", "#{session_name}"}); err == nil {
+		for _, name := range strings.Split(strings.TrimSpace(output), "\n") {
+			running[name] = true
+		}
+	}
+	sessions := make([]treemux.Session, 0, len(entries))
+	for _, entry := range entries {
+		if running[entry.Name] {
+			continue
+		}
+		group := func() []string { return entry.Group }
+		if len(entry.Group) == 0 {
+			group = sync.OnceValue(func() []string { return repoGroup(entry.Path) })
+		}
+		sessions = append(sessions, ExecSession{tmuxClient: e.tmuxClient, options: e.Restore, Entry: entry, group: group})
+	}
+	return sessions, nil
+}
+
+// ParseExecEntries parses and validates plugin output. Blank lines are skipped.
+func ParseExecEntries(output string) ([]ExecEntry, error) {
+	var entries []ExecEntry
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var entry ExecEntry
+		if err := json.Unmarshal([]byte(text), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: invalid JSON: %w", line, err)
+		}
+		if err := entry.validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ExecSession is a session described by a plugin.
+type ExecSession struct {
+	tmuxClient tmuxClient
+	options    snapshot.RestoreOptions
+	Entry      ExecEntry
+
+	// group returns the plugin's group, or lazily computes the repository grouping.
+	group func() []string
+}
+
+// Attach creates the session unless it already exists, then attaches to it.
+func (s ExecSession) Attach() error {
+	if _, err := s.tmuxClient.RunCmd([]string{"has-session", "-t", "=" + s.Entry.Name}); err != nil {
+		if err := s.create(); err != nil {
+			return err
+		}
+	}
+	return s.tmuxClient.AttachOrSwitch(s.Entry.Name)
+}
+
+// create creates the session, from its template if it has one, and starts its command.
+func (s ExecSession) create() error {
+	if s.Entry.Template != "" {
+		saved, err := snapshot.Load()
+		if err != nil {
+			return err
+		}
+		template, ok := saved.Session(s.Entry.Template)
+		if !ok {
+			return fmt.Errorf("template %q is not a saved session", s.Entry.Template)
+		}
+		if err := snapshot.Restore(s.tmuxClient, rebase(template, s.Entry.Name, s.Entry.Path), s.options); err != nil {
+			return err
+		}
+	} else {
+		args := []string{"new-session", "-d", "-s", s.Entry.Name}
+		if s.Entry.Path != "" {
+			args = append(args, "-c", s.Entry.Path)
+		}
+		if _, err := s.tmuxClient.RunCmd(args); err != nil {
+			return fmt.Errorf("failed to create session %q: %w", s.Entry.Name, err)
+		}
+	}
+	if s.Entry.Command != "" {
+		if _, err := s.tmuxClient.RunCmd([]string{"send-keys", "-t", "=" + s.Entry.Name + ":", s.Entry.Command, "Enter"}); err != nil {
+			return fmt.Errorf("failed to start %q: %w", s.Entry.Command, err)
+		}
+	}
+	return nil
+}
+
+// rebase copies the saved session template as a session named name and rooted at path. Panes
+// inside the template's directory move to the same place under path, and the others to path.
+func rebase(template snapshot.Session, name, path string) snapshot.Session {
+	if path == "" {
+		path = template.Path
+	}
+	session := snapshot.Session{Name: name, Path: path}
+	for _, window := range template.Windows {
+		rebased := window
+		rebased.Panes = make([]snapshot.Pane, len(window.Panes))
+		for i, pane := range window.Panes {
+			rebased.Panes[i] = pane
+			rebased.Panes[i].Path = path
+			if rel, err := filepath.Rel(template.Path, pane.Path); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+				rebased.Panes[i].Path = filepath.Join(path, rel)
+			}
+		}
+		session.Windows = append(session.Windows, rebased)
+	}
+	return session
+}
+
+// Name returns the tmux session name.
+func (s ExecSession) Name() string {
+	return s.Entry.Name
+}
+
+// Path returns the directory the session is created in.
+func (s ExecSession) Path() string {
+	return s.Entry.Path
+}
+
+// Group returns the plugin's group, or the repository and worktree containing the session's
+// directory.
+func (s ExecSession) Group() []string {
+	if s.group == nil {
+		return nil
+	}
+	return s.group()
+}
+
+// String returns the session as a string for display in a prompter.
+func (s ExecSession) String() string {
+	if s.Entry.Description != "" {
+		return fmt.Sprintf("  %s - %s", s.Entry.Name, s.Entry.Description)
+	}
+	return "  " + s.Entry.Name
+}
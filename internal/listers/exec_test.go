@@ -0,0 +1,112 @@
+package listers
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ian-howell/treemux/internal/snapshot"
+	"github.com/ian-howell/treemux/internal/tmuxfake"
+)
+
+func TestParseExecEntries(t *testing.T) {
+	entries, err := ParseExecEntries(`{"name": "api", "path": "/src/api", "group": ["services"], "future": true}
+
+{"version": 1, "name": "a.b:c"}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name != "api" || !slices.Equal(entries[0].Group, []string{"services"}) || entries[1].Name != "a_b_c" {
+		t.Fatalf("expected api and a_b_c, got %+v", entries)
+	}
+
+	for output, want := range map[string]string{
+		`{"name": "api"`:                 "line 1: invalid JSON",
+		"\n" + `{"path": "/src"}`:        "line 2: missing name",
+		`{"name": "api", "path": "src"}`: `line 1: path "src" is not absolute`,
+		`{"version": 2, "name": "api"}`:  "line 1: protocol version 2 is newer than the supported version 1",
+	} {
+		if _, err := ParseExecEntries(output); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseExecEntries(%q): expected %q, got %v", output, want, err)
+		}
+	}
+}
+
+func TestExecRun(t *testing.T) {
+	server := &tmuxfake.Server{}
+
+	// The plugin learns the protocol version from the environment.
+	lister := NewExec(server, []string{"sh", "-c", `printf '{"name": "v%s"}\n' "$TREEMUX_PLUGIN_VERSION"`})
+	if sessions, err := lister.List(); err != nil || len(sessions) != 1 || sessions[0].Name() != "v1" {
+		t.Fatalf("expected session v1, got %v, %v", sessions, err)
+	}
+
+	lister = NewExec(server, []string{"sh", "-c", "echo broken >&2; exit 3"})
+	if _, err := lister.List(); err == nil || !strings.Contains(err.Error(), "exit status 3: broken") {
+		t.Fatalf("expected the plugin's failure, got %v", err)
+	}
+
+	lister = NewExec(server, []string{"sleep", "5"})
+	lister.Timeout = 50 * time.Millisecond
+	if _, err := lister.List(); err == nil || !strings.Contains(err.Error(), "timed out after 50ms") {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+
+	// Processes the plugin started are killed with it, rather than holding its output open.
+	lister = NewExec(server, []string{"sh", "-c", "sleep 5; echo '{\"name\": \"late\"}'"})
+	lister.Timeout = 50 * time.Millisecond
+	start := time.Now()
+	if _, err := lister.List(); err == nil || !strings.Contains(err.Error(), "timed out") || time.Since(start) > 2*time.Second {
+		t.Fatalf("expected the plugin and its children killed at the timeout, got %v after %s", err, time.Since(start))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewExec(server, []string{"sleep", "5"}).ListContext(ctx); err == nil || !strings.Contains(err.Error(), "context canceled") {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
+
+func TestExecTemplate(t *testing.T) {
+	t.Setenv("TREEMUX_STATE_DIR", t.TempDir())
+	saved := snapshot.Snapshot{Version: snapshot.Version, Sessions: []snapshot.Session{{
+		Name: "service",
+		Path: "/src/template",
+		Windows: []snapshot.Window{{Index: 0, Name: "edit", Active: true, Panes: []snapshot.Pane{
+			{Index: 0, Path: "/src/template/cmd", Command: "vim", Active: true},
+			{Index: 1, Path: "/tmp"},
+		}}},
+	}}}
+	if err := saved.Save(); err != nil {
+		t.Fatal(err)
+	}
+	server := &tmuxfake.Server{}
+	lister := NewExec(server, plugin(`{"name": "billing", "path": "/src/billing", "template": "service", "command": "make"}`))
+	lister.Restore.Commands = true
+	sessions, err := lister.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sessions[0].Attach(); err != nil {
+		t.Fatal(err)
+	}
+
+	created := server.Sessions()
+	if len(created) != 1 || created[0].Name != "billing" || created[0].Path != "/src/billing" {
+		t.Fatalf("expected session billing in /src/billing, got %+v", created)
+	}
+	panes := created[0].Windows[0].Panes
+	var paths []string
+	for _, pane := range panes {
+		paths = append(paths, pane.Path)
+	}
+	if !slices.Equal(paths, []string{"/src/billing/cmd", "/src/billing"}) {
+		t.Fatalf("expected the panes rebased onto /src/billing, got %v", paths)
+	}
+	if content := strings.Join(panes[0].Content, "\n"); !strings.Contains(content, "vim") || !strings.Contains(content, "make") {
+		t.Fatalf("expected vim and make started in the active pane, got %q", content)
+	}
+}
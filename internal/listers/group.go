@@ -0,0 +1,24 @@
+package listers
+
+import (
+	"path/filepath"
+
+	"github.com/ian-howell/treemux/internal/git"
+)
+
+// repoGroup groups a directory under its repository and worktree, e.g. ["treemux", "main"]. It
+// returns nil for directories outside a git repository.
+func repoGroup(dir string) []string {
+	if dir == "" {
+		return nil
+	}
+	info, err := git.Worktree(dir)
+	if err != nil {
+		return nil
+	}
+	worktree := info.Branch
+	if worktree == "" {
+		worktree = filepath.Base(info.Root)
+	}
+	return []string{filepath.Base(info.MainRoot), worktree}
+}
@@ -0,0 +1,237 @@
+package listers
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/ian-howell/treemux/internal/daemon"
+	"github.com/ian-howell/treemux/internal/snapshot"
+	"github.com/ian-howell/treemux/internal/tmuxfake"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// fakeDaemon serves a fixed index, or fails with err if set, and records attach requests.
+type fakeDaemon struct {
+	sessions []daemon.Session
+	err      error
+	attached []string
+}
+
+func (d *fakeDaemon) List() ([]daemon.Session, error) { return d.sessions, d.err }
+
+func (d *fakeDaemon) Attach(session, client string) error {
+	d.attached = append(d.attached, session+" "+client)
+	return nil
+}
+
+// fakeAttacher attaches through a fake server on behalf of a client.
+type fakeAttacher struct {
+	*tmuxfake.Server
+	tty string
+}
+
+func (a fakeAttacher) ClientTTY() string { return a.tty }
+
+func TestListers(t *testing.T) {
+	t.Setenv("TREEMUX_STATE_DIR", t.TempDir())
+	newServer := func() *tmuxfake.Server {
+		server := &tmuxfake.Server{}
+		server.AddSession("work", "/work", "edit", "logs")
+		server.AddSession("notes", "/notes")
+		server.AddClient(tmuxfake.Client{Name: "client-1", TTY: "/dev/pts/1", Session: "notes"})
+		server.AddClient(tmuxfake.Client{Name: "client-2", Session: "work", Control: true})
+		return server
+	}
+
+	tests := []struct {
+		name   string
+		lister func(server *tmuxfake.Server) treemux.Lister
+		want   []string
+	}{
+		{
+			name:   "active sessions, most recently attached first",
+			lister: func(server *tmuxfake.Server) treemux.Lister { return NewActiveSessions(server) },
+			want:   []string{"  work", "* notes"},
+		},
+		{
+			name: "active sessions with windows",
+			lister: func(server *tmuxfake.Server) treemux.Lister {
+				lister := NewActiveSessions(server)
+				lister.IncludeWindows = true
+				return lister
+			},
+			want: []string{"  work", "    0: edit", "    1: logs", "* notes", "    0: sh"},
+		},
+		{
+			name: "daemon",
+			lister: func(server *tmuxfake.Server) treemux.Lister {
+				return NewDaemon(&fakeDaemon{sessions: []daemon.Session{{Name: "work", Label: "work (daemon)"}}}, fakeAttacher{Server: server})
+			},
+			want: []string{"work (daemon)"},
+		},
+		{
+			name: "daemon that exited falls back to listing directly",
+			lister: func(server *tmuxfake.Server) treemux.Lister {
+				lister := NewDaemon(&fakeDaemon{err: errors.New("connection reset by peer")}, fakeAttacher{Server: server})
+				lister.Fallback = NewActiveSessions(server)
+				return lister
+			},
+			want: []string{"  work", "* notes"},
+		},
+		{
+			name:   "snapshot without saved sessions",
+			lister: func(server *tmuxfake.Server) treemux.Lister { return NewSnapshot(server) },
+			want:   nil,
+		},
+		{
+			name: "exec plugin, without running sessions",
+			lister: func(server *tmuxfake.Server) treemux.Lister {
+				return NewExec(server, plugin(`{"name": "work"}`, `{"name": "PROJ-1", "description": "Fix login"}`, `{"name": "v1.2"}`))
+			},
+			want: []string{"  PROJ-1 - Fix login", "  v1_2"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sessions, err := test.lister(newServer()).List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var labels []string
+			for _, session := range sessions {
+				labels = append(labels, session.String())
+			}
+			if !slices.Equal(labels, test.want) {
+				t.Fatalf("expected %q, got %q", test.want, labels)
+			}
+		})
+	}
+}
+
+func TestAttachers(t *testing.T) {
+	t.Setenv("TREEMUX_STATE_DIR", t.TempDir())
+	saved := snapshot.Snapshot{Version: snapshot.Version, Sessions: []snapshot.Session{{
+		Name: "old",
+		Path: "/old",
+		Windows: []snapshot.Window{{Index: 0, Name: "edit", Active: true, Panes: []snapshot.Pane{
+			{Path: "/old", Command: "vim", Active: true},
+		}}},
+	}}}
+	if err := saved.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// current is the client treemux runs in, or "" outside tmux.
+		current string
+		// pick selects the session to attach to.
+		pick func(server *tmuxfake.Server, daemon *fakeDaemon) (treemux.Session, error)
+		// wantSession is the session the client ends up in.
+		wantSession string
+		// wantDaemon are the attach requests the daemon receives.
+		wantDaemon []string
+	}{
+		{
+			name:        "active session switches the current client",
+			current:     "/dev/pts/1",
+			pick:        listed(func(server *tmuxfake.Server, _ *fakeDaemon) treemux.Lister { return NewActiveSessions(server) }, "work"),
+			wantSession: "work",
+		},
+		{
+			name:        "active session attaches outside tmux",
+			pick:        listed(func(server *tmuxfake.Server, _ *fakeDaemon) treemux.Lister { return NewActiveSessions(server) }, "work"),
+			wantSession: "work",
+		},
+		{
+			name:    "window switches to its session",
+			current: "/dev/pts/1",
+			pick: listed(func(server *tmuxfake.Server, _ *fakeDaemon) treemux.Lister {
+				lister := NewActiveSessions(server)
+				lister.IncludeWindows = true
+				return lister
+			}, "work:1"),
+			wantSession: "work",
+		},
+		{
+			name:        "saved session is restored",
+			current:     "/dev/pts/1",
+			pick:        listed(func(server *tmuxfake.Server, _ *fakeDaemon) treemux.Lister { return NewSnapshot(server) }, "old"),
+			wantSession: "old",
+		},
+		{
+			name:    "plugin session is created",
+			current: "/dev/pts/1",
+			pick: listed(func(server *tmuxfake.Server, _ *fakeDaemon) treemux.Lister {
+				return NewExec(server, plugin(`{"name": "PROJ-1", "path": "/src/api", "command": "make"}`))
+			}, "PROJ-1"),
+			wantSession: "PROJ-1",
+		},
+		{
+			name:    "plugin session is created from a template",
+			current: "/dev/pts/1",
+			pick: listed(func(server *tmuxfake.Server, _ *fakeDaemon) treemux.Lister {
+				return NewExec(server, plugin(`{"name": "PROJ-2", "path": "/src/web", "template": "old"}`))
+			}, "PROJ-2"),
+			wantSession: "PROJ-2",
+		},
+		{
+			name:    "daemon switches the current client",
+			current: "/dev/pts/1",
+			pick: listed(func(server *tmuxfake.Server, d *fakeDaemon) treemux.Lister {
+				return NewDaemon(d, fakeAttacher{Server: server, tty: "/dev/pts/1"})
+			}, "work"),
+			wantSession: "notes",
+			wantDaemon:  []string{"work /dev/pts/1"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &tmuxfake.Server{Current: test.current}
+			server.AddSession("work", "/work", "edit", "logs")
+			server.AddSession("notes", "/notes")
+			if test.current != "" {
+				server.AddClient(tmuxfake.Client{Name: "client-1", TTY: test.current, Session: "notes"})
+			}
+			d := &fakeDaemon{sessions: []daemon.Session{{Name: "work"}}}
+
+			session, err := test.pick(server, d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := session.Attach(); err != nil {
+				t.Fatal(err)
+			}
+			clients := server.Clients()
+			if len(clients) != 1 || clients[0].Session != test.wantSession {
+				t.Fatalf("expected a single client in %q, got %+v", test.wantSession, clients)
+			}
+			if !slices.Equal(d.attached, test.wantDaemon) {
+				t.Fatalf("expected daemon attaches %q, got %q", test.wantDaemon, d.attached)
+			}
+		})
+	}
+}
+
+// plugin returns an Exec plugin command printing lines.
+func plugin(lines ...string) []string {
+	return append([]string{"sh", "-c", `printf '%s\n' "$@"`, "plugin"}, lines...)
+}
+
+// listed returns a pick function that lists the lister built by newLister and picks the session
+// with the given name.
+func listed(newLister func(*tmuxfake.Server, *fakeDaemon) treemux.Lister, name string) func(*tmuxfake.Server, *fakeDaemon) (treemux.Session, error) {
+	return func(server *tmuxfake.Server, d *fakeDaemon) (treemux.Session, error) {
+		sessions, err := newLister(server, d).List()
+		if err != nil {
+			return nil, err
+		}
+		for _, session := range sessions {
+			if session.Name() == name {
+				return session, nil
+			}
+		}
+		return nil, nil
+	}
+}
@@ -0,0 +1,95 @@
+package listers
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/ian-howell/treemux/internal/snapshot"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// Snapshot lists the saved sessions that are not running, e.g. after the tmux server restarted.
+// Selecting one restores it from the snapshot before attaching.
+type Snapshot struct {
+	tmuxClient tmuxClient
+
+	// Restore configures how selected sessions are rebuilt.
+	Restore snapshot.RestoreOptions
+}
+
+func NewSnapshot(tmuxClient tmuxClient) *Snapshot {
+	return &Snapshot{tmuxClient: tmuxClient}
+}
+
+// List returns the saved sessions that are not running. An unreadable snapshot is logged and
+// lists nothing, so that it does not break the picker.
+func (s *Snapshot) List() ([]treemux.Session, error) {
+	saved, err := snapshot.Load()
+	if err != nil {
+		slog.Warn("ignoring unreadable snapshot", slog.Any("error", err))
+		return nil, nil
+	}
+	running := map[string]bool{}
+	// Without a server nothing is running, which is exactly when the snapshot matters most.
+	if output, err := s.tmuxClient.RunCmd([]string{"list-sessions", "-F", "#{session_name}"}); err == nil {
+		for _, name := range strings.Split(strings.TrimSpace(output), "\n") {
+			running[name] = true
+		}
+	}
+	sessions := make([]treemux.Session, 0, len(saved.Sessions))
+	for _, session := range saved.Sessions {
+		if running[session.Name] {
+			continue
+		}
+		sessions = append(sessions, SavedSession{
+			tmuxClient: s.tmuxClient,
+			options:    s.Restore,
+			Session:    session,
+			group:      sync.OnceValue(func() []string { return repoGroup(session.Path) }),
+		})
+	}
+	return sessions, nil
+}
+
+// SavedSession is a session from the snapshot that is not running.
+type SavedSession struct {
+	tmuxClient tmuxClient
+	options    snapshot.RestoreOptions
+	Session    snapshot.Session
+
+	// group lazily computes the session's repository grouping, since it costs a git invocation.
+	group func() []string
+}
+
+// Attach restores the session and attaches to it.
+func (s SavedSession) Attach() error {
+	if err := snapshot.Restore(s.tmuxClient, s.Session, s.options); err != nil {
+		return err
+	}
+	return s.tmuxClient.AttachOrSwitch(s.Session.Name)
+}
+
+// Name returns the tmux session name.
+func (s SavedSession) Name() string {
+	return s.Session.Name
+}
+
+// Path returns the session's saved working directory.
+func (s SavedSession) Path() string {
+	return s.Session.Path
+}
+
+// Group returns the repository and worktree containing the session's directory.
+func (s SavedSession) Group() []string {
+	if s.group == nil {
+		return nil
+	}
+	return s.group()
+}
+
+// String returns the session as a string for display in a prompter.
+func (s SavedSession) String() string {
+	return fmt.Sprintf("  %s (saved)", s.Session.Name)
+}
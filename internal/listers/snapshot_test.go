@@ -0,0 +1,75 @@
+package listers
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ian-howell/treemux/internal/snapshot"
+	"github.com/ian-howell/treemux/internal/tmuxfake"
+)
+
+func TestSnapshot(t *testing.T) {
+	t.Setenv("TREEMUX_STATE_DIR", t.TempDir())
+	saved := snapshot.Snapshot{Version: snapshot.Version, Sessions: []snapshot.Session{
+		{Name: "work", Path: "/work"},
+		{Name: "notes", Path: "/notes"},
+	}}
+	if err := saved.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		running []string
+		// stopped fails every tmux command, as when no server is running.
+		stopped bool
+		want    []string
+	}{
+		{name: "no server", stopped: true, want: []string{"work", "notes"}},
+		{name: "some running", running: []string{"work", "other"}, want: []string{"notes"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &tmuxfake.Server{Stopped: test.stopped}
+			for _, name := range test.running {
+				server.AddSession(name, "/"+name)
+			}
+			sessions, err := NewSnapshot(server).List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, session := range sessions {
+				names = append(names, session.Name())
+			}
+			if !slices.Equal(names, test.want) {
+				t.Fatalf("expected %v, got %v", test.want, names)
+			}
+		})
+	}
+}
+
+func TestSnapshotUnreadable(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{name: "corrupt", contents: "{"},
+		{name: "newer version", contents: `{"version": 1000, "sessions": [{"name": "work"}]}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("TREEMUX_STATE_DIR", dir)
+			if err := os.WriteFile(filepath.Join(dir, "snapshot.json"), []byte(test.contents), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			sessions, err := NewSnapshot(&tmuxfake.Server{}).List()
+			if err != nil || len(sessions) != 0 {
+				t.Fatalf("expected no sessions and no error, got %v, %v", sessions, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package listers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ian-howell/treemux/internal/tmux"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// Stdin lists candidates read from another program, e.g. `fd -t d | treemux --from-stdin`. Each
+// line is a directory, a session name, or a JSON object in the format of ExecEntry. Selecting a
+// candidate joins its session or creates it.
+type Stdin struct {
+	tmuxClient tmuxClient
+
+	// lines is the input, read up front so that the terminal can be handed to the prompter.
+	lines []string
+}
+
+// NewStdin reads r to the end and returns a lister of its lines.
+func NewStdin(tmuxClient tmuxClient, r io.Reader) (*Stdin, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stdin: %w", err)
+	}
+	return &Stdin{tmuxClient: tmuxClient, lines: strings.Split(string(data), "\n")}, nil
+}
+
+// List turns each line into a session. A directory becomes the session already rooted there, or
+// a new session named after it; any other line names a session.
+func (s *Stdin) List() ([]treemux.Session, error) {
+	// Sessions are matched by path, so that a directory rejoins its session whatever its name.
+	named := map[string]string{}
+	byPath := map[string]string{}
+	if output, err := s.tmuxClient.RunCmd([]string{"list-sessions", "-F", "#{session_name}\t#{session_path}"}); err == nil {
+		for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+			name, path, _ := strings.Cut(line, "\t")
+			named[name] = path
+			if _, ok := byPath[path]; !ok && path != "" {
+				byPath[path] = name
+			}
+		}
+	}
+
+	var sessions []treemux.Session
+	seen := map[string]bool{}
+	for i, line := range s.lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		entry, err := s.entry(line, named, byPath)
+		if err != nil {
+			return nil, fmt.Errorf("stdin line %d: %w", i+1, err)
+		}
+		if seen[entry.Name] {
+			continue
+		}
+		seen[entry.Name] = true
+		// Later lines must not take the name, and the same directory again is the same session.
+		named[entry.Name] = entry.Path
+		if _, ok := byPath[entry.Path]; !ok && entry.Path != "" {
+			byPath[entry.Path] = entry.Name
+		}
+		group := func() []string { return entry.Group }
+		if len(entry.Group) == 0 {
+			group = sync.OnceValue(func() []string { return repoGroup(entry.Path) })
+		}
+		sessions = append(sessions, ExecSession{tmuxClient: s.tmuxClient, Entry: entry, group: group})
+	}
+	return sessions, nil
+}
+
+// entry describes the session for a line of input.
+func (s *Stdin) entry(line string, named, byPath map[string]string) (ExecEntry, error) {
+	if strings.HasPrefix(line, "{") {
+		var entry ExecEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return ExecEntry{}, fmt.Errorf("invalid JSON: %w", err)
+		}
+		// validate normalizes the name, so it must run before entry is returned.
+		err := entry.validate()
+		return entry, err
+	}
+	if info, err := os.Stat(expandHome(line)); err == nil && info.IsDir() {
+		path, err := filepath.Abs(expandHome(line))
+		if err != nil {
+			return ExecEntry{}, err
+		}
+		if name, ok := byPath[path]; ok {
+			return ExecEntry{Name: name, Path: path, Description: line}, nil
+		}
+		return ExecEntry{Name: freeName(tmux.SessionName(filepath.Base(path)), named), Path: path, Description: line}, nil
+	}
+	name := tmux.SessionName(line)
+	return ExecEntry{Name: name, Path: named[name]}, nil
+}
+
+// freeName returns name, suffixed with -2, -3 and so on if a session already has it.
+func freeName(name string, named map[string]string) string {
+	candidate := name
+	for n := 2; ; n++ {
+		if _, taken := named[candidate]; !taken {
+			return candidate
+		}
+		candidate = name + "-" + strconv.Itoa(n)
+	}
+}
+
+// expandHome expands a leading ~ to the home directory.
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~")
+	if !ok || rest != "" && !strings.HasPrefix(rest, "/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return home + rest
+}
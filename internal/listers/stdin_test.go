@@ -0,0 +1,78 @@
+package listers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ian-howell/treemux/internal/tmuxfake"
+)
+
+func TestStdin(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"api", "web", "other/api"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(root)
+	t.Setenv("HOME", root)
+
+	server := &tmuxfake.Server{}
+	server.AddSession("frontend", filepath.Join(root, "web"))
+	server.AddSession("notes", "/notes")
+
+	input := strings.Join([]string{
+		"api",
+		"",
+		"~/web",
+		root + "/api",
+		"other/api",
+		"notes",
+		"scratch",
+		`{"name": "ticket", "path": "/src/ticket", "description": "Fix login"}`,
+	}, "\n")
+	lister, err := NewStdin(server, strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions, err := lister.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct{ name, path string }{
+		{"api", filepath.Join(root, "api")},
+		// A directory with a running session rejoins it.
+		{"frontend", filepath.Join(root, "web")},
+		// Another directory with the same base name gets a name of its own.
+		{"api-2", filepath.Join(root, "other/api")},
+		{"notes", "/notes"},
+		{"scratch", ""},
+		{"ticket", "/src/ticket"},
+	}
+	if len(sessions) != len(want) {
+		t.Fatalf("expected %d sessions, got %v", len(want), sessions)
+	}
+	for i, w := range want {
+		if sessions[i].Name() != w.name || sessions[i].Path() != w.path {
+			t.Errorf("session %d: expected %s at %q, got %s at %q", i, w.name, w.path, sessions[i].Name(), sessions[i].Path())
+		}
+	}
+
+	if err := sessions[0].Attach(); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.Sessions(); len(got) != 3 || got[2].Name != "api" || got[2].Path != filepath.Join(root, "api") {
+		t.Fatalf("expected session api to be created, got %v", got)
+	}
+
+	lister, err = NewStdin(server, strings.NewReader("api\n{\"path\": \"/src\"}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lister.List(); err == nil || !strings.Contains(err.Error(), "stdin line 2: missing name") {
+		t.Fatalf("expected an error for line 2, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package listers
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// Watched decorates a lister so that it is listed again whenever sessions change, and delivers
+// the new lists through Updates.
+type Watched struct {
+	lister treemux.Lister
+
+	// changes signals that the sessions may have changed.
+	changes <-chan struct{}
+
+	start   sync.Once
+	updates chan []treemux.Session
+
+	// mu guards last, the signature of the most recent list.
+	mu   sync.Mutex
+	last []string
+}
+
+// NewWatched wraps lister so that it is listed again on every signal from changes, until changes
+// is closed.
+func NewWatched(lister treemux.Lister, changes <-chan struct{}) *Watched {
+	return &Watched{
+		lister:  lister,
+		changes: changes,
+		updates: make(chan []treemux.Session, 1),
+	}
+}
+
+// List lists the wrapped lister.
+func (w *Watched) List() ([]treemux.Session, error) {
+	sessions, err := w.lister.List()
+	if err != nil {
+		return nil, err
+	}
+	w.changed(sessions)
+	return sessions, nil
+}
+
+// String describes the lister for logs.
+func (w *Watched) String() string {
+	return "watched " + treemux.ListerName(w.lister)
+}
+
+// Updates starts following changes. Lists equal to the previous one are skipped, and only the
+// newest list is kept if the receiver falls behind.
+func (w *Watched) Updates() <-chan []treemux.Session {
+	w.start.Do(func() {
+		go w.run()
+	})
+	return w.updates
+}
+
+// run lists the wrapped lister on every change until changes is closed.
+func (w *Watched) run() {
+	defer close(w.updates)
+	for range w.changes {
+		sessions, err := w.lister.List()
+		if err != nil || !w.changed(sessions) {
+			continue
+		}
+		// This is the only sender, so once a stale list is dropped the send cannot block.
+		select {
+		case <-w.updates:
+		default:
+		}
+		w.updates <- sessions
+	}
+}
+
+// changed records sessions as the most recent list and reports whether it differs from the
+// previous one.
+func (w *Watched) changed(sessions []treemux.Session) bool {
+	signature := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		signature = append(signature, session.Name()+"\x00"+session.Path()+"\x00"+session.String())
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if slices.Equal(signature, w.last) {
+		return false
+	}
+	w.last = signature
+	return true
+}
@@ -0,0 +1,43 @@
+package listers
+
+import (
+	"testing"
+
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// queueLister returns the lists sent to it, one per call.
+type queueLister chan []treemux.Session
+
+func (l queueLister) List() ([]treemux.Session, error) {
+	return <-l, nil
+}
+
+func TestWatched(t *testing.T) {
+	one := []treemux.Session{stubSession{name: "one"}}
+	two := []treemux.Session{stubSession{name: "one"}, stubSession{name: "two"}}
+	lister := make(queueLister, 1)
+	changes := make(chan struct{})
+	watched := NewWatched(lister, changes)
+
+	lister <- one
+	if sessions, err := watched.List(); err != nil || len(sessions) != 1 {
+		t.Fatalf("expected 1 session, got %d, %v", len(sessions), err)
+	}
+	updates := watched.Updates()
+
+	// A change that leaves the list as it was is not delivered, so the first update is the second
+	// change.
+	changes <- struct{}{}
+	lister <- one
+	changes <- struct{}{}
+	lister <- two
+	if sessions := <-updates; len(sessions) != 2 {
+		t.Fatalf("expected an update with 2 sessions, got %d", len(sessions))
+	}
+
+	close(changes)
+	if _, ok := <-updates; ok {
+		t.Fatal("expected updates to close with changes")
+	}
+}
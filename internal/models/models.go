@@ -5,6 +5,9 @@ type Session struct {
 	// Name is the name of the session.
 	Name string
 
+	// Path is the session's working directory.
+	Path string
+
 	// Attached is whether the session is currently attached to a terminal.
 	Attached bool
 
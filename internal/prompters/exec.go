@@ -0,0 +1,93 @@
+package prompters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ian-howell/treemux/internal/executor"
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// Exec prompts with an external line-oriented selector, such as dmenu, `rofi -dmenu`,
+// `wofi --dmenu` or fzf. The session labels are written to the selector's stdin, one per line and
+// in order, and the selector prints the chosen line. Empty output or a non-zero exit, which is how
+// these selectors report that they were dismissed, cancels the prompt. Whatever the selector writes
+// to stderr is passed on, so that a selector that fails is not mistaken for a silent cancel.
+type Exec struct {
+	// Command is the selector's argv.
+	Command []string
+
+	// Index reads the zero-based index of the chosen line instead of the line itself, as printed by
+	// `rofi -dmenu -format i`. It tells apart sessions with the same label.
+	Index bool
+
+	// Stderr receives the selector's stderr. Nil uses os.Stderr.
+	Stderr io.Writer
+}
+
+// Prompt runs the selector once. Updates are ignored, since the selector has already read its
+// input by the time they arrive.
+func (p *Exec) Prompt(sessions []treemux.Session, _ int, _ <-chan []treemux.Session) (treemux.Session, error) {
+	if len(sessions) == 0 {
+		return nil, treemux.ErrNoSessions
+	}
+	if len(p.Command) == 0 {
+		return nil, errors.New("exec prompter: no command")
+	}
+
+	labels := make([]string, len(sessions))
+	for i, session := range sessions {
+		// A label must fit on its line.
+		labels[i] = strings.ReplaceAll(session.String(), "\n", " ")
+	}
+	stdout, stderr, err := executor.Run(context.Background(), executor.Command{
+		Name:  p.Command[0],
+		Args:  p.Command[1:],
+		Stdin: strings.Join(labels, "\n") + "\n",
+	})
+	if stderr != "" {
+		w := p.Stderr
+		if w == nil {
+			w = os.Stderr
+		}
+		io.WriteString(w, stderr)
+	}
+	if _, ok := errors.AsType[*executor.ExitError](err); ok {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to run selector %q: %w", p.Command[0], err)
+	}
+	choice := strings.TrimRight(stdout, "\r\n")
+	if i := strings.IndexByte(choice, '\n'); i >= 0 {
+		// Selectors that allow several choices print one per line; the first wins.
+		choice = choice[:i]
+	}
+	if strings.TrimSpace(choice) == "" {
+		return nil, nil
+	}
+
+	if p.Index {
+		i, err := strconv.Atoi(strings.TrimSpace(choice))
+		if err != nil || i < 0 || i >= len(sessions) {
+			return nil, fmt.Errorf("selector printed %q, which is not the index of a session", choice)
+		}
+		return sessions[i], nil
+	}
+	if i := slices.Index(labels, choice); i >= 0 {
+		return sessions[i], nil
+	}
+	// Some selectors trim the lines they print.
+	for i, label := range labels {
+		if strings.TrimSpace(label) == strings.TrimSpace(choice) {
+			return sessions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("selector printed %q, which is not a session", choice)
+}
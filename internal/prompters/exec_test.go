@@ -0,0 +1,82 @@
+package prompters
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+func TestExec(t *testing.T) {
+	// Pointers tell apart the sessions sharing a label.
+	sessions := []treemux.Session{
+		&stubSession{name: "* work"},
+		&stubSession{name: "  notes"},
+		&stubSession{name: "  notes"},
+	}
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input")
+	// selector is a stub selector: it saves its input and runs script.
+	selector := func(script string) []string {
+		path := filepath.Join(dir, "selector")
+		if err := os.WriteFile(path, []byte("#!/bin/sh\ncat > '"+input+"'\n"+script+"\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return []string{path}
+	}
+
+	tests := []struct {
+		name   string
+		script string
+		index  bool
+		// want is the index of the chosen session, or -1 for a canceled prompt.
+		want    int
+		wantErr string
+	}{
+		{name: "line", script: `echo "* work"`, want: 0},
+		{name: "trimmed line", script: `echo notes`, want: 1},
+		{name: "index", script: `echo 2`, index: true, want: 2},
+		{name: "empty output cancels", script: `true`, want: -1},
+		{name: "non-zero exit cancels", script: `echo "* work"; exit 1`, want: -1},
+		{name: "unknown line", script: `echo other`, wantErr: `selector printed "other", which is not a session`},
+		{name: "index out of range", script: `echo 3`, index: true, wantErr: "not the index of a session"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			prompter := &Exec{Command: selector(test.script), Index: test.index}
+			session, err := prompter.Prompt(sessions, 0, nil)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("expected %q, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.want < 0 {
+				if session != nil {
+					t.Fatalf("expected the prompt canceled, got %v", session)
+				}
+			} else if session != sessions[test.want] {
+				t.Fatalf("expected session %d, got %v", test.want, session)
+			}
+			if data, _ := os.ReadFile(input); string(data) != "* work\n  notes\n  notes\n" {
+				t.Fatalf("expected the labels on stdin, got %q", data)
+			}
+		})
+	}
+
+	// A failing selector cancels the prompt, but its stderr says why.
+	var stderr strings.Builder
+	prompter := &Exec{Command: selector(`echo "rofi: no display" >&2; exit 1`), Stderr: &stderr}
+	if session, err := prompter.Prompt(sessions, 0, nil); session != nil || err != nil || stderr.String() != "rofi: no display\n" {
+		t.Fatalf("expected a canceled prompt with the selector's stderr, got %v, %v, %q", session, err, stderr.String())
+	}
+
+	if _, err := (&Exec{Command: []string{filepath.Join(dir, "missing")}}).Prompt(sessions, 0, nil); err == nil {
+		t.Fatal("expected a missing selector to fail rather than cancel")
+	}
+}
@@ -1,11 +1,11 @@
 package prompters
 
 import (
-	"errors"
 	"fmt"
 	"os"
 
 	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/x/term"
 
@@ -16,38 +16,29 @@ type Huh struct {
 	FullScreen bool
 }
 
-type sessionChoice struct {
-	label   string
-	session treemux.Session
-}
-
-func (c sessionChoice) String() string {
-	return c.label
-}
-
-func (p *Huh) Prompt(sessions []treemux.Session) (treemux.Session, error) {
+func (p *Huh) Prompt(sessions []treemux.Session, initial int, updates <-chan []treemux.Session) (treemux.Session, error) {
 	if len(sessions) == 0 {
-		return nil, fmt.Errorf("no sessions available")
+		return nil, treemux.ErrNoSessions
 	}
 
-	choices := make([]huh.Option[sessionChoice], 0, len(sessions))
-	for _, session := range sessions {
-		label := session.String()
-		choices = append(choices, huh.NewOption(label, sessionChoice{
-			label:   label,
-			session: session,
-		}))
-	}
+	choices := &huhChoices{}
+	choices.set(sessions)
 
-	selected := sessionChoice{}
-	form := huh.NewForm(
-		huh.NewGroup(
-			huh.NewSelect[sessionChoice]().
-				Title("Select a session").
-				Options(choices...).
-				Value(&selected),
-		),
-	)
+	// Options are keyed by session identity rather than position so that the cursor stays on the
+	// same session when the list is updated. The selected value must be set before the options so
+	// that the cursor starts on it.
+	selected := sessionKey(sessions[initial])
+	field := huh.NewSelect[string]().
+		Title("Select a session").
+		Value(&selected).
+		Options(choices.options()...).
+		Validate(func(key string) error {
+			if _, ok := choices.session(key).(treemux.ClosedSession); ok {
+				return fmt.Errorf("this session was closed")
+			}
+			return nil
+		})
+	form := huh.NewForm(huh.NewGroup(field))
 
 	if p.FullScreen {
 		form = form.WithWidth(screenWidth()).WithHeight(screenHeight())
@@ -59,35 +50,164 @@ func (p *Huh) Prompt(sessions []treemux.Session) (treemux.Session, error) {
 		"esc",
 	))
 	form = form.WithKeyMap(keymap)
+	form.SubmitCmd = tea.Quit
+	form.CancelCmd = tea.Quit
 
-	if err := form.Run(); err != nil {
-		if errors.Is(err, huh.ErrUserAborted) {
-			// If the user aborted, send a nil Session with a nil err to indicate that
-			// no selection was made.
-			return nil, nil
-		}
+	// Huh uses stderr for its output.
+	model := &huhModel{form: form, field: field, choices: choices, updates: updates}
+	if _, err := tea.NewProgram(model, tea.WithOutput(os.Stderr), tea.WithReportFocus()).Run(); err != nil {
 		return nil, err
 	}
+	if form.State != huh.StateCompleted {
+		// If the user aborted, send a nil Session with a nil err to indicate that
+		// no selection was made.
+		return nil, nil
+	}
 
-	return selected.session, nil
+	return choices.session(selected), nil
 }
 
-// screenWidth returns the width of the terminal screen. If it cannot be determined, it returns a default width.
-func screenWidth() int {
-	// Huh uses stderr for its output, so we get the terminal size from stderr.
-	width, _, err := term.GetSize(os.Stderr.Fd())
-	if err != nil || width <= 0 {
-		return 0
+// sessionKey identifies a session across list updates.
+func sessionKey(session treemux.Session) string {
+	return session.Name() + "\x00" + session.Path()
+}
+
+// huhChoices holds the current session options.
+type huhChoices struct {
+	sessions []treemux.Session
+}
+
+// set replaces the sessions.
+func (c *huhChoices) set(sessions []treemux.Session) {
+	c.sessions = sessions
+}
+
+// options returns the select options for the current sessions.
+func (c *huhChoices) options() []huh.Option[string] {
+	options := make([]huh.Option[string], 0, len(c.sessions))
+	for _, session := range c.sessions {
+		options = append(options, huh.NewOption(session.String(), sessionKey(session)))
+	}
+	return options
+}
+
+// session returns the current session with the given key.
+func (c *huhChoices) session(key string) treemux.Session {
+	for _, session := range c.sessions {
+		if sessionKey(session) == key {
+			return session
+		}
+	}
+	return nil
+}
+
+// sessionsMsg carries an updated session list into the bubbletea event loop.
+type sessionsMsg []treemux.Session
+
+// waitForSessions returns a command that delivers the next session list update. It yields
+// nothing once updates is closed.
+func waitForSessions(updates <-chan []treemux.Session) tea.Cmd {
+	if updates == nil {
+		return nil
 	}
+	return func() tea.Msg {
+		sessions, ok := <-updates
+		if !ok {
+			return nil
+		}
+		return sessionsMsg(sessions)
+	}
+}
+
+// huhModel runs a huh form while feeding it session list updates.
+type huhModel struct {
+	form    *huh.Form
+	field   *huh.Select[string]
+	choices *huhChoices
+	updates <-chan []treemux.Session
+
+	// pending holds an update that arrived while the user was typing a filter. Replacing the
+	// options re-lists them unfiltered, so it is applied once the filter is done.
+	pending []treemux.Session
+
+	// size is the last window size. huh only sizes the form when it changes, so it is replayed
+	// after the options change.
+	size tea.WindowSizeMsg
+}
+
+// Init implements tea.Model.
+func (m *huhModel) Init() tea.Cmd {
+	return tea.Batch(m.form.Init(), waitForSessions(m.updates))
+}
+
+// Update implements tea.Model.
+func (m *huhModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+	switch msg := msg.(type) {
+	case sessionsMsg:
+		m.pending = msg
+		cmds = append(cmds, waitForSessions(m.updates))
+	case tea.WindowSizeMsg:
+		m.size = msg
+	}
+	// Options are replaced before the form handles the message, since that is when huh renders
+	// them. The cursor follows the selected value, so it stays on the same session.
+	if m.pending != nil && !m.field.GetFiltering() {
+		m.choices.set(m.pending)
+		m.field.Options(m.choices.options()...)
+		m.pending = nil
+		if m.size.Height > 0 {
+			m.form.Update(m.size)
+		}
+	}
+	form, cmd := m.form.Update(msg)
+	m.form = form.(*huh.Form)
+	cmds = append(cmds, cmd)
+	if m.pending != nil && !m.field.GetFiltering() {
+		// The filter was just closed, so apply the deferred update right away.
+		cmds = append(cmds, func() tea.Msg { return applyPendingMsg{} })
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// applyPendingMsg asks huhModel to apply a deferred update.
+type applyPendingMsg struct{}
+
+// View implements tea.Model.
+func (m *huhModel) View() string {
+	if m.form.State != huh.StateNormal {
+		return ""
+	}
+	return m.form.View()
+}
+
+// screenWidth returns the width of the terminal screen, or 0 if it cannot be determined.
+func screenWidth() int {
+	width, _ := screenSize()
 	return width
 }
 
-// screenHeight returns the height of the terminal screen. If it cannot be determined, it returns a default height.
+// screenHeight returns the height of the terminal screen, or 0 if it cannot be determined.
 func screenHeight() int {
-	// Huh uses stderr for its output, so we get the terminal size from stderr.
-	_, height, err := term.GetSize(os.Stderr.Fd())
-	if err != nil || height <= 0 {
-		return 0
-	}
+	_, height := screenSize()
 	return height
 }
+
+// screenSize returns the size of the terminal the prompters render on. Huh uses stderr for its
+// output, so that is measured first; in a tmux popup it is the popup itself. If stderr is not a
+// terminal, the controlling terminal is measured instead.
+func screenSize() (int, int) {
+	if width, height, err := term.GetSize(os.Stderr.Fd()); err == nil && width > 0 && height > 0 {
+		return width, height
+	}
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return 0, 0
+	}
+	defer tty.Close()
+	width, height, err := term.GetSize(tty.Fd())
+	if err != nil {
+		return 0, 0
+	}
+	return width, height
+}
@@ -0,0 +1,413 @@
+package prompters
+
+import (
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// Tree presents sessions as a tree grouped by repository and worktree. Group nodes can be
+// expanded and collapsed, and filtering keeps the ancestors of every match visible.
+type Tree struct {
+	FullScreen bool
+}
+
+// Prompt builds a tree from the sessions and lets the user pick one.
+func (p *Tree) Prompt(sessions []treemux.Session, initial int, updates <-chan []treemux.Session) (treemux.Session, error) {
+	if len(sessions) == 0 {
+		return nil, treemux.ErrNoSessions
+	}
+
+	model := newTreeModel(treemux.BuildTree(sessions), sessions[initial])
+	model.updates = updates
+
+	// Like huh, render on stderr so that stdout stays free for callers.
+	opts := []tea.ProgramOption{tea.WithOutput(os.Stderr)}
+	if p.FullScreen {
+		opts = append(opts, tea.WithAltScreen())
+	}
+	final, err := tea.NewProgram(model, opts...).Run()
+	if err != nil {
+		return nil, err
+	}
+	// A nil Session with a nil err indicates that no selection was made.
+	return final.(treeModel).selected, nil
+}
+
+// treeKeyMap holds the key bindings of the tree prompter.
+type treeKeyMap struct {
+	Up       key.Binding
+	Down     key.Binding
+	Expand   key.Binding
+	Collapse key.Binding
+	Toggle   key.Binding
+	Select   key.Binding
+	Quit     key.Binding
+}
+
+var treeKeys = treeKeyMap{
+	Up:       key.NewBinding(key.WithKeys("up", "ctrl+p", "ctrl+k")),
+	Down:     key.NewBinding(key.WithKeys("down", "ctrl+n", "ctrl+j")),
+	Expand:   key.NewBinding(key.WithKeys("right", "ctrl+l")),
+	Collapse: key.NewBinding(key.WithKeys("left")),
+	Toggle:   key.NewBinding(key.WithKeys("tab")),
+	Select:   key.NewBinding(key.WithKeys("enter")),
+	Quit:     key.NewBinding(key.WithKeys("ctrl+c", "esc")),
+}
+
+var (
+	treeTitleStyle    = lipgloss.NewStyle().Bold(true)
+	treeCursorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("212"))
+	treeGroupStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("99"))
+	treeHelpStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	treeNoMatchesText = "  no matches"
+)
+
+// treeRow is a visible line of the tree.
+type treeRow struct {
+	node  *treemux.Node
+	depth int
+}
+
+// treeModel is the bubbletea model behind Tree.
+type treeModel struct {
+	root     *treemux.Node
+	filter   textinput.Model
+	expanded map[*treemux.Node]bool
+	rows     []treeRow
+	cursor   int
+	height   int
+	offset   int
+	selected treemux.Session
+	updates  <-chan []treemux.Session
+}
+
+// newTreeModel returns a model with every node expanded and the cursor on the initial session.
+func newTreeModel(root *treemux.Node, initial treemux.Session) treeModel {
+	filter := textinput.New()
+	filter.Prompt = "> "
+	filter.Placeholder = "filter"
+	// Without a width the placeholder is cut down to its first character.
+	filter.Width = 40
+	filter.Focus()
+
+	m := treeModel{
+		root:     root,
+		filter:   filter,
+		expanded: map[*treemux.Node]bool{},
+	}
+	root.Walk(func(node *treemux.Node, _ int) bool {
+		m.expanded[node] = true
+		return true
+	})
+	m.refresh()
+	// Sessions are not necessarily comparable, so the initial one is found by name.
+	for i, row := range m.rows {
+		if row.node.Session != nil && row.node.Session.Name() == initial.Name() {
+			m.cursor = i
+			break
+		}
+	}
+	m.scroll()
+	return m
+}
+
+// Init implements tea.Model.
+func (m treeModel) Init() tea.Cmd {
+	return tea.Batch(textinput.Blink, waitForSessions(m.updates))
+}
+
+// Update implements tea.Model.
+func (m treeModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+		m.scroll()
+		return m, nil
+	case sessionsMsg:
+		m.replace(treemux.BuildTree(msg))
+		return m, waitForSessions(m.updates)
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, treeKeys.Quit):
+			return m, tea.Quit
+		case key.Matches(msg, treeKeys.Up):
+			m.move(-1)
+			return m, nil
+		case key.Matches(msg, treeKeys.Down):
+			m.move(1)
+			return m, nil
+		case key.Matches(msg, treeKeys.Expand):
+			m.setExpanded(true)
+			return m, nil
+		case key.Matches(msg, treeKeys.Collapse):
+			m.setExpanded(false)
+			return m, nil
+		case key.Matches(msg, treeKeys.Toggle):
+			if node := m.current(); node != nil && len(node.Children) > 0 {
+				m.setExpanded(!m.expanded[node])
+			}
+			return m, nil
+		case key.Matches(msg, treeKeys.Select):
+			node := m.current()
+			if node == nil {
+				return m, nil
+			}
+			if node.Session == nil {
+				m.setExpanded(!m.expanded[node])
+				return m, nil
+			}
+			if _, closed := node.Session.(treemux.ClosedSession); closed {
+				return m, nil
+			}
+			m.selected = node.Session
+			return m, tea.Quit
+		}
+	}
+
+	query := m.filter.Value()
+	var cmd tea.Cmd
+	m.filter, cmd = m.filter.Update(msg)
+	if m.filter.Value() != query {
+		m.cursor = 0
+		m.refresh()
+	}
+	return m, cmd
+}
+
+// View implements tea.Model.
+func (m treeModel) View() string {
+	var b strings.Builder
+	b.WriteString(treeTitleStyle.Render("Select a session"))
+	b.WriteString("\n")
+	b.WriteString(m.filter.View())
+	b.WriteString("\n")
+	if len(m.rows) == 0 {
+		b.WriteString(treeHelpStyle.Render(treeNoMatchesText))
+		b.WriteString("\n")
+	}
+	end := len(m.rows)
+	if limit := m.visibleRows(); limit > 0 && m.offset+limit < end {
+		end = m.offset + limit
+	}
+	for i := m.offset; i < end; i++ {
+		b.WriteString(m.renderRow(i))
+		b.WriteString("\n")
+	}
+	b.WriteString(treeHelpStyle.Render("↑/↓ move • ←/→ collapse/expand • enter select • esc quit"))
+	return b.String()
+}
+
+// renderRow renders the i-th visible row.
+func (m treeModel) renderRow(i int) string {
+	row := m.rows[i]
+	cursor := "  "
+	if i == m.cursor {
+		cursor = treeCursorStyle.Render("> ")
+	}
+	marker := "  "
+	if len(row.node.Children) > 0 {
+		marker = "▸ "
+		if m.expanded[row.node] || m.filtering() {
+			marker = "▾ "
+		}
+	}
+	indent := strings.Repeat("  ", row.depth-1)
+	if row.node.Session != nil {
+		label := strings.TrimSpace(row.node.Session.String())
+		if _, closed := row.node.Session.(treemux.ClosedSession); closed {
+			label = treeHelpStyle.Render(label)
+		}
+		return cursor + indent + marker + label
+	}
+	return cursor + indent + marker + treeGroupStyle.Render(row.node.Name)
+}
+
+// visibleRows returns how many rows fit on screen, or 0 if the height is unknown.
+func (m treeModel) visibleRows() int {
+	// Leave room for the title, the filter and the help line.
+	if m.height <= 3 {
+		return 0
+	}
+	return m.height - 3
+}
+
+// current returns the node under the cursor.
+func (m treeModel) current() *treemux.Node {
+	if m.cursor < 0 || m.cursor >= len(m.rows) {
+		return nil
+	}
+	return m.rows[m.cursor].node
+}
+
+// move moves the cursor by delta rows.
+func (m *treeModel) move(delta int) {
+	m.cursor = max(0, min(len(m.rows)-1, m.cursor+delta))
+	m.scroll()
+}
+
+// scroll keeps the cursor within the visible rows.
+func (m *treeModel) scroll() {
+	limit := m.visibleRows()
+	if limit == 0 {
+		m.offset = 0
+		return
+	}
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+limit {
+		m.offset = m.cursor - limit + 1
+	}
+}
+
+// setExpanded expands or collapses the node under the cursor. Collapsing a node that is already
+// collapsed, or a leaf, moves the cursor to its parent instead.
+func (m *treeModel) setExpanded(expanded bool) {
+	node := m.current()
+	if node == nil {
+		return
+	}
+	if !expanded && (len(node.Children) == 0 || !m.expanded[node]) {
+		m.moveToParent()
+		return
+	}
+	m.expanded[node] = expanded
+	m.refresh()
+}
+
+// replace swaps in a rebuilt tree, carrying over which groups were collapsed and keeping the
+// cursor on the same node when it still exists.
+func (m *treeModel) replace(root *treemux.Node) {
+	current := m.current()
+	collapsed := map[string]bool{}
+	var cursorPath string
+	walkPaths(m.root, func(node *treemux.Node, path string) {
+		if !m.expanded[node] {
+			collapsed[path] = true
+		}
+		if node == current {
+			cursorPath = path
+		}
+	})
+
+	m.root = root
+	m.expanded = map[*treemux.Node]bool{}
+	var cursorNode *treemux.Node
+	walkPaths(root, func(node *treemux.Node, path string) {
+		m.expanded[node] = !collapsed[path]
+		if current != nil && path == cursorPath {
+			cursorNode = node
+		}
+	})
+	m.rows = nil
+	m.refresh()
+	for i, row := range m.rows {
+		if row.node == cursorNode {
+			m.cursor = i
+			break
+		}
+	}
+	m.scroll()
+}
+
+// walkPaths calls fn for root and each of its descendants along with a key made of the names
+// leading to the node, which identifies it across rebuilt trees.
+func walkPaths(root *treemux.Node, fn func(node *treemux.Node, path string)) {
+	var walk func(node *treemux.Node, path string)
+	walk = func(node *treemux.Node, path string) {
+		fn(node, path)
+		for _, child := range node.Children {
+			walk(child, path+"\x00"+child.Name)
+		}
+	}
+	walk(root, "")
+}
+
+// moveToParent moves the cursor to the parent of the node under it.
+func (m *treeModel) moveToParent() {
+	depth := m.rows[m.cursor].depth
+	for i := m.cursor - 1; i >= 0; i-- {
+		if m.rows[i].depth < depth {
+			m.cursor = i
+			m.scroll()
+			return
+		}
+	}
+}
+
+// filtering reports whether a filter query is active.
+func (m treeModel) filtering() bool {
+	return strings.TrimSpace(m.filter.Value()) != ""
+}
+
+// refresh recomputes the visible rows. While filtering, every matching node and all of its
+// ancestors are shown regardless of their expanded state.
+func (m *treeModel) refresh() {
+	query := strings.ToLower(strings.TrimSpace(m.filter.Value()))
+	var matches map[*treemux.Node]bool
+	if query != "" {
+		matches = map[*treemux.Node]bool{}
+		markMatches(m.root, query, matches)
+	}
+
+	selectedNode := m.current()
+
+	m.rows = m.rows[:0]
+	m.root.Walk(func(node *treemux.Node, depth int) bool {
+		if node == m.root {
+			return true
+		}
+		if matches != nil && !matches[node] {
+			return false
+		}
+		m.rows = append(m.rows, treeRow{node: node, depth: depth})
+		return matches != nil || m.expanded[node]
+	})
+
+	// Keep the cursor on the same node when it is still visible.
+	m.cursor = min(m.cursor, max(0, len(m.rows)-1))
+	for i, row := range m.rows {
+		if row.node == selectedNode {
+			m.cursor = i
+			break
+		}
+	}
+	m.scroll()
+}
+
+// markMatches marks every node whose label contains query, along with all of its ancestors and
+// descendants. It reports whether node or any descendant matched.
+func markMatches(node *treemux.Node, query string, matches map[*treemux.Node]bool) bool {
+	if strings.Contains(strings.ToLower(nodeLabel(node)), query) {
+		node.Walk(func(node *treemux.Node, _ int) bool {
+			matches[node] = true
+			return true
+		})
+		return true
+	}
+	matched := false
+	for _, child := range node.Children {
+		if markMatches(child, query, matches) {
+			matched = true
+		}
+	}
+	if matched {
+		matches[node] = true
+	}
+	return matched
+}
+
+// nodeLabel returns the text a node is matched against.
+func nodeLabel(node *treemux.Node) string {
+	if node.Session != nil {
+		return node.Name + " " + node.Session.String()
+	}
+	return node.Name
+}
@@ -0,0 +1,111 @@
+package prompters
+
+import (
+	"slices"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/ian-howell/treemux/internal/treemux"
+)
+
+// stubSession is a Session with a fixed name and group.
+type stubSession struct {
+	name  string
+	group []string
+}
+
+func (s stubSession) Attach() error   { return nil }
+func (s stubSession) String() string  { return s.name }
+func (s stubSession) Name() string    { return s.name }
+func (s stubSession) Path() string    { return "" }
+func (s stubSession) Group() []string { return s.group }
+
+func TestTreeModel(t *testing.T) {
+	sessions := []treemux.Session{
+		stubSession{name: "treemux", group: []string{"treemux", "main"}},
+		stubSession{name: "treemux/feature", group: []string{"treemux", "feature"}},
+		stubSession{name: "dotfiles", group: []string{"dotfiles", "main"}},
+		stubSession{name: "scratch"},
+	}
+	m := newTreeModel(treemux.BuildTree(sessions), sessions[2])
+
+	want := []string{"treemux", "main", "treemux", "feature", "treemux/feature", "dotfiles", "main", "dotfiles", "scratch"}
+	if got := rowNames(m); !slices.Equal(got, want) {
+		t.Fatalf("expected rows %v, got %v", want, got)
+	}
+	if m.current().Session.Name() != "dotfiles" {
+		t.Fatalf("expected cursor on the initial session, got %q", m.current().Name)
+	}
+
+	// Collapsing the session moves to its worktree, and collapsing again hides the session.
+	m = update(m, tea.KeyMsg{Type: tea.KeyLeft})
+	m = update(m, tea.KeyMsg{Type: tea.KeyLeft})
+	want = []string{"treemux", "main", "treemux", "feature", "treemux/feature", "dotfiles", "main", "scratch"}
+	if got := rowNames(m); !slices.Equal(got, want) {
+		t.Fatalf("expected collapsed rows %v, got %v", want, got)
+	}
+
+	// Filtering shows matches with their ancestors, even through collapsed nodes. Terminals send
+	// Backspace as ctrl+h, which edits the filter rather than collapsing.
+	for _, r := range "dotfx" {
+		m = update(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	m = update(m, tea.KeyMsg{Type: tea.KeyCtrlH})
+	want = []string{"dotfiles", "main", "dotfiles"}
+	if got := rowNames(m); !slices.Equal(got, want) {
+		t.Fatalf("expected filtered rows %v, got %v", want, got)
+	}
+
+	m = update(m, tea.KeyMsg{Type: tea.KeyDown})
+	m = update(m, tea.KeyMsg{Type: tea.KeyDown})
+	m = update(m, tea.KeyMsg{Type: tea.KeyEnter})
+	if m.selected == nil || m.selected.Name() != "dotfiles" {
+		t.Fatalf("expected dotfiles to be selected, got %v", m.selected)
+	}
+}
+
+func update(m treeModel, msg tea.Msg) treeModel {
+	model, _ := m.Update(msg)
+	return model.(treeModel)
+}
+
+func rowNames(m treeModel) []string {
+	names := make([]string, 0, len(m.rows))
+	for _, row := range m.rows {
+		names = append(names, row.node.Name)
+	}
+	return names
+}
+
+func TestTreeModelUpdates(t *testing.T) {
+	sessions := []treemux.Session{
+		stubSession{name: "alpha"},
+		stubSession{name: "beta"},
+		stubSession{name: "gamma"},
+	}
+	m := newTreeModel(treemux.BuildTree(sessions), sessions[0])
+	for _, r := range "a" {
+		m = update(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	m = update(m, tea.KeyMsg{Type: tea.KeyDown})
+
+	// The query and the cursor survive an update, and the closed session stays in place.
+	m = update(m, sessionsMsg{sessions[0], treemux.ClosedSession{Session: sessions[1]}, sessions[2], stubSession{name: "delta"}})
+	if m.filter.Value() != "a" {
+		t.Fatalf("expected the query to be kept, got %q", m.filter.Value())
+	}
+	want := []string{"alpha", "beta", "gamma", "delta"}
+	if got := rowNames(m); !slices.Equal(got, want) {
+		t.Fatalf("expected rows %v, got %v", want, got)
+	}
+	if got := m.current().Name; got != "beta" {
+		t.Fatalf("expected the cursor to stay on beta, got %q", got)
+	}
+
+	// Closed sessions cannot be selected.
+	m = update(m, tea.KeyMsg{Type: tea.KeyEnter})
+	if m.selected != nil {
+		t.Fatalf("expected no selection, got %v", m.selected)
+	}
+}
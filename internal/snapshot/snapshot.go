@@ -0,0 +1,263 @@
+// Package snapshot saves the layout of tmux sessions and rebuilds them after the server restarts.
+//
+// A snapshot records each session's windows and panes, with their layouts, working directories
+// and foreground commands. It is persisted in the state directory as versioned JSON.
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ian-howell/treemux/internal/state"
+	"github.com/ian-howell/treemux/internal/tmux"
+)
+
+// Version is the snapshot format version. It is bumped on incompatible changes, and snapshots
+// written by a newer version are refused rather than misread.
+const Version = 1
+
+// stateFile is the state file holding the snapshot.
+const stateFile = "snapshot.json"
+
+// Snapshot is the saved state of every session.
+type Snapshot struct {
+	Version  int       `json:"version"`
+	Saved    time.Time `json:"saved"`
+	Sessions []Session `json:"sessions"`
+}
+
+// Session is a saved session.
+type Session struct {
+	Name    string   `json:"name"`
+	Path    string   `json:"path"`
+	Windows []Window `json:"windows"`
+}
+
+// Window is a saved window.
+type Window struct {
+	Index  int    `json:"index"`
+	Name   string `json:"name"`
+	Layout string `json:"layout"`
+	Active bool   `json:"active,omitempty"`
+	Panes  []Pane `json:"panes"`
+}
+
+// Pane is a saved pane.
+type Pane struct {
+	Index int    `json:"index"`
+	Path  string `json:"path"`
+
+	// Command is the command line running in the foreground of the pane, or "" if the pane was
+	// idle at its shell prompt.
+	Command string `json:"command,omitempty"`
+
+	Active bool `json:"active,omitempty"`
+}
+
+// tmuxClient runs tmux commands.
+type tmuxClient interface {
+	RunCmd(args []string) (stdout string, err error)
+}
+
+// Load reads the saved snapshot. A missing snapshot is empty rather than an error.
+func Load() (Snapshot, error) {
+	var snapshot Snapshot
+	if err := state.ReadJSON(stateFile, &snapshot); err != nil {
+		return Snapshot{}, err
+	}
+	if snapshot.Version > Version {
+		return Snapshot{}, fmt.Errorf("snapshot version %d is newer than the supported version %d", snapshot.Version, Version)
+	}
+	return snapshot, nil
+}
+
+// Save persists the snapshot.
+func (s Snapshot) Save() error {
+	return state.WriteJSON(stateFile, s)
+}
+
+// Session returns the saved session with the given name.
+func (s Snapshot) Session(name string) (Session, bool) {
+	i := slices.IndexFunc(s.Sessions, func(session Session) bool { return session.Name == name })
+	if i < 0 {
+		return Session{}, false
+	}
+	return s.Sessions[i], true
+}
+
+// paneFormat lists every field of a pane that a snapshot records, separated by tabs since names
+// and paths may contain spaces.
+const paneFormat = "#{session_name}\t#{session_path}\t#{window_index}\t#{window_name}\t#{window_layout}\t" +
+	"#{window_active}\t#{pane_index}\t#{pane_current_path}\t#{pane_active}\t#{pane_pid}\t#{pane_current_command}"
+
+// Capture snapshots every running session.
+func Capture(tmuxClient tmuxClient) (Snapshot, error) {
+	output, err := tmuxClient.RunCmd([]string{"list-panes", "-a", "-F", paneFormat})
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshot := Snapshot{Version: Version, Saved: time.Now()}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 11 {
+			continue
+		}
+		windowIndex, _ := strconv.Atoi(fields[2])
+		paneIndex, _ := strconv.Atoi(fields[6])
+		pid, _ := strconv.Atoi(fields[9])
+
+		// list-panes lists the panes of a window, and the windows of a session, together.
+		sessions := snapshot.Sessions
+		if len(sessions) == 0 || sessions[len(sessions)-1].Name != fields[0] {
+			snapshot.Sessions = append(snapshot.Sessions, Session{Name: fields[0], Path: fields[1]})
+		}
+		session := &snapshot.Sessions[len(snapshot.Sessions)-1]
+		if len(session.Windows) == 0 || session.Windows[len(session.Windows)-1].Index != windowIndex {
+			session.Windows = append(session.Windows, Window{
+				Index:  windowIndex,
+				Name:   fields[3],
+				Layout: fields[4],
+				Active: fields[5] == "1",
+			})
+		}
+		window := &session.Windows[len(session.Windows)-1]
+		window.Panes = append(window.Panes, Pane{
+			Index:   paneIndex,
+			Path:    fields[7],
+			Command: foregroundCommand(pid, fields[10]),
+			Active:  fields[8] == "1",
+		})
+	}
+	return snapshot, nil
+}
+
+// shells are the programs that are considered idle when they run in the foreground of a pane.
+var shells = []string{"sh", "bash", "zsh", "fish", "dash", "ksh", "mksh", "tcsh", "csh", "nu", "elvish", "xonsh"}
+
+// foregroundCommand returns the command line of the foreground process of the pane whose shell has
+// the given pid, or "" if the shell itself is in the foreground. Where /proc is unavailable the
+// command line is approximated by the command name tmux reports.
+func foregroundCommand(shellPID int, current string) string {
+	if command, ok := procForegroundCommand(shellPID); ok {
+		return command
+	}
+	if slices.Contains(shells, strings.TrimPrefix(current, "-")) {
+		return ""
+	}
+	return current
+}
+
+// procForegroundCommand reads the foreground process group of the shell's terminal from /proc, and
+// the command line of its leader.
+func procForegroundCommand(shellPID int) (string, bool) {
+	if shellPID <= 0 {
+		return "", false
+	}
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(shellPID), "stat"))
+	if err != nil {
+		return "", false
+	}
+	// The command name in parentheses may itself contain spaces, so fields are counted after it.
+	end := strings.LastIndexByte(string(stat), ')')
+	if end < 0 {
+		return "", false
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	// The fields after the name are state, ppid, pgrp, session, tty_nr and tpgid.
+	if len(fields) < 6 {
+		return "", false
+	}
+	foreground, err := strconv.Atoi(fields[5])
+	if err != nil || foreground <= 0 {
+		return "", false
+	}
+	if foreground == shellPID {
+		return "", true
+	}
+	cmdline, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(foreground), "cmdline"))
+	if err != nil || len(cmdline) == 0 {
+		return "", false
+	}
+	args := strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+	for i, arg := range args {
+		args[i] = tmux.Quote(arg)
+	}
+	return strings.Join(args, " "), true
+}
+
+// RestoreOptions configures Restore.
+type RestoreOptions struct {
+	// Commands, when set, reruns each pane's foreground command.
+	Commands bool
+}
+
+// Restore rebuilds session: its windows at their saved indexes, their panes in the saved layouts
+// and directories, and the active window and panes. The session must not exist.
+func Restore(tmuxClient tmuxClient, session Session, options RestoreOptions) error {
+	target := "=" + session.Name
+	// The session starts with a placeholder window, so that it is rooted at its saved path even
+	// when its panes have moved elsewhere. Saved windows replace or join it, and it is removed last.
+	output, err := tmuxClient.RunCmd([]string{"new-session", "-d", "-s", session.Name, "-c", session.Path, "-P", "-F", "#{window_id}"})
+	if err != nil {
+		return fmt.Errorf("failed to restore session %q: %w", session.Name, err)
+	}
+	placeholder := strings.TrimSpace(output)
+
+	activeWindow, restored := "", 0
+	for _, window := range session.Windows {
+		if len(window.Panes) == 0 {
+			continue
+		}
+		windowTarget := fmt.Sprintf("%s:%d", target, window.Index)
+		output, err := tmuxClient.RunCmd([]string{"new-window", "-d", "-k", "-t", windowTarget, "-n", window.Name, "-c", window.Panes[0].Path, "-P", "-F", "#{pane_id}"})
+		if err != nil {
+			return fmt.Errorf("failed to restore window %s: %w", windowTarget, err)
+		}
+		panes := []string{strings.TrimSpace(output)}
+		for _, saved := range window.Panes[1:] {
+			// Splitting the newest pane keeps the panes in their saved order, which the layout expects.
+			output, err := tmuxClient.RunCmd([]string{"split-window", "-d", "-t", panes[len(panes)-1], "-c", saved.Path, "-P", "-F", "#{pane_id}"})
+			if err != nil {
+				return fmt.Errorf("failed to restore pane in window %s: %w", windowTarget, err)
+			}
+			panes = append(panes, strings.TrimSpace(output))
+		}
+		if window.Layout != "" {
+			// A layout saved on a larger terminal may not fit; the panes are kept either way.
+			_, _ = tmuxClient.RunCmd([]string{"select-layout", "-t", windowTarget, window.Layout})
+		}
+		for i, saved := range window.Panes {
+			if options.Commands && saved.Command != "" {
+				if _, err := tmuxClient.RunCmd([]string{"send-keys", "-t", panes[i], saved.Command, "Enter"}); err != nil {
+					return fmt.Errorf("failed to restart %q: %w", saved.Command, err)
+				}
+			}
+			if saved.Active {
+				if _, err := tmuxClient.RunCmd([]string{"select-pane", "-t", panes[i]}); err != nil {
+					return fmt.Errorf("failed to select pane: %w", err)
+				}
+			}
+		}
+		if window.Active {
+			activeWindow = windowTarget
+		}
+		restored++
+	}
+
+	// The placeholder is gone already if a saved window took its index, and is kept if no window
+	// could be restored since the session would end with it.
+	if restored > 0 {
+		_, _ = tmuxClient.RunCmd([]string{"kill-window", "-t", placeholder})
+	}
+	if activeWindow != "" {
+		if _, err := tmuxClient.RunCmd([]string{"select-window", "-t", activeWindow}); err != nil {
+			return fmt.Errorf("failed to select window: %w", err)
+		}
+	}
+	return nil
+}
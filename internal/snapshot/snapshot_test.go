@@ -0,0 +1,109 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ian-howell/treemux/internal/state"
+	"github.com/ian-howell/treemux/internal/tmuxtest"
+)
+
+func TestCaptureAndRestore(t *testing.T) {
+	server := tmuxtest.NewServer(t)
+	client := server.Client()
+	tmux := server.Run
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tmux("new-session", "-d", "-s", "work", "-c", dir, "-n", "edit", "-x", "120", "-y", "40")
+	tmux("new-window", "-d", "-t", "=work:5", "-n", "logs", "-c", sub)
+	tmux("split-window", "-d", "-h", "-t", "=work:5", "-c", dir)
+	tmux("select-window", "-t", "=work:5")
+	tmux("send-keys", "-t", "=work:5.0", "sleep 100", "Enter")
+	waitFor(t, func() bool { return tmux("display-message", "-p", "-t", "=work:5.0", "#{pane_current_command}") == "sleep" })
+
+	saved, err := Capture(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Sessions) != 1 || len(saved.Sessions[0].Windows) != 2 {
+		t.Fatalf("expected one session with two windows, got %+v", saved.Sessions)
+	}
+	logs := saved.Sessions[0].Windows[1]
+	if logs.Index != 5 || logs.Name != "logs" || !logs.Active || len(logs.Panes) != 2 {
+		t.Fatalf("unexpected logs window %+v", logs)
+	}
+	if logs.Panes[0].Command != "sleep 100" || logs.Panes[1].Command != "" {
+		t.Fatalf("expected only the first pane to run sleep, got %+v", logs.Panes)
+	}
+
+	tmux("kill-session", "-t", "=work")
+	session, ok := saved.Session("work")
+	if !ok {
+		t.Fatal("expected the work session in the snapshot")
+	}
+	if err := Restore(client, session, RestoreOptions{Commands: true}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return tmux("display-message", "-p", "-t", "=work:5.0", "#{pane_current_command}") == "sleep" })
+
+	restored, err := Capture(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := restored.Session("work")
+	if got.Path != session.Path || len(got.Windows) != len(session.Windows) {
+		t.Fatalf("expected %+v, got %+v", session, got)
+	}
+	for i, window := range got.Windows {
+		want := session.Windows[i]
+		if window.Index != want.Index || window.Name != want.Name || geometry(window.Layout) != geometry(want.Layout) || window.Active != want.Active {
+			t.Fatalf("expected window %+v, got %+v", want, window)
+		}
+		for j, pane := range window.Panes {
+			if pane.Path != want.Panes[j].Path || pane.Command != want.Panes[j].Command {
+				t.Fatalf("expected pane %+v, got %+v", want.Panes[j], pane)
+			}
+		}
+	}
+}
+
+func TestLoadRefusesNewerVersion(t *testing.T) {
+	t.Setenv("TREEMUX_STATE_DIR", t.TempDir())
+	if saved, err := Load(); err != nil || len(saved.Sessions) != 0 {
+		t.Fatalf("expected an empty snapshot, got %+v, %v", saved, err)
+	}
+	if err := state.WriteJSON(stateFile, Snapshot{Version: Version + 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(); err == nil {
+		t.Fatal("expected a newer snapshot to be refused")
+	}
+}
+
+// paneIDs matches the pane ID following each cell's geometry in a layout.
+var paneIDs = regexp.MustCompile(`(\d+x\d+,\d+,\d+),\d+`)
+
+// geometry strips the checksum and pane IDs from layout, which differ between equal layouts.
+func geometry(layout string) string {
+	_, layout, _ = strings.Cut(layout, ",")
+	return paneIDs.ReplaceAllString(layout, "$1")
+}
+
+// waitFor polls condition until it holds, failing the test after a few seconds.
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if condition() {
+			return
+		}
+	}
+	t.Fatal("timed out waiting for tmux")
+}
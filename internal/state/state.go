@@ -0,0 +1,98 @@
+// Package state locates and manages treemux's persistent state directory.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Dir returns the treemux state directory, creating it if it does not exist.
+//
+// The directory is taken from $TREEMUX_STATE_DIR when set, then $XDG_STATE_HOME/treemux, and
+// finally ~/.local/state/treemux.
+func Dir() (string, error) {
+	dir := os.Getenv("TREEMUX_STATE_DIR")
+	if dir == "" {
+		base := os.Getenv("XDG_STATE_HOME")
+		if base == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", fmt.Errorf("failed to locate home directory: %w", err)
+			}
+			base = filepath.Join(home, ".local", "state")
+		}
+		dir = filepath.Join(base, "treemux")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create state dir: %w", err)
+	}
+	return dir, nil
+}
+
+// Path returns the path of the named file inside the state directory.
+func Path(name string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// ReadJSON decodes the named state file into v. A missing file leaves v untouched and is not an
+// error.
+func ReadJSON(name string, v any) error {
+	path, err := Path(name)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteJSON atomically replaces the named state file with the JSON encoding of v.
+func WriteJSON(name string, v any) error {
+	path, err := Path(name)
+	if err != nil {
+		return err
+	}
+	return WriteJSONFile(path, v)
+}
+
+// WriteJSONFile atomically replaces the file at path with the JSON encoding of v. The data is
+// written to a temporary file of its own and renamed into place, so that concurrent writers never
+// see or publish each other's partial writes.
+func WriteJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state file: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
@@ -2,23 +2,144 @@
 package tmux
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 
+	"github.com/ian-howell/treemux/internal/executor"
 	gotmux "github.com/jubnzv/go-tmux"
 )
 
+// ErrNoServer is returned when no tmux server is listening on the socket.
+var ErrNoServer = errors.New("tmux is not running")
+
 // Client provides tmux operations used by treemux.
-type Client struct{}
+type Client struct {
+	// client is the tty of the tmux client to act on, or "" for the current one.
+	client string
+
+	// socket is the path of the tmux server's socket, or "" for the default server.
+	socket string
+
+	// runner runs the tmux commands.
+	runner Runner
+
+	// version asks tmux for its version the first time it is called.
+	version func() (Version, error)
+}
+
+// Runner runs tmux commands on behalf of a Client. The default runner executes the tmux binary;
+// others may answer from a model of tmux instead, as for a dry run.
+type Runner interface {
+	// Run runs a tmux command and returns its output.
+	Run(args []string) (stdout, stderr string, err error)
+
+	// Exec replaces the current process with a tmux command, as when attaching from outside tmux.
+	Exec(args []string) error
+}
+
+// execRunner runs the tmux binary through an executor, which traces every command. A nil executor
+// stands for the default one.
+type execRunner struct {
+	executor *executor.Executor
+}
+
+func (r execRunner) Run(args []string) (string, string, error) {
+	return r.get().Run(context.Background(), executor.Command{Name: "tmux", Args: args})
+}
+
+func (r execRunner) Exec(args []string) error {
+	return r.get().Exec(executor.Command{Name: "tmux", Args: args})
+}
+
+// get returns the executor to run tmux with.
+func (r execRunner) get() *executor.Executor {
+	if r.executor == nil {
+		return executor.Default()
+	}
+	return r.executor
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithClient makes the client act on the tmux client with the given tty rather than the current
+// one. Attaching then always switches that client, which is what a popup needs: the popup is not
+// itself a client, and attaching from it would nest tmux.
+func WithClient(tty string) Option {
+	return func(c *Client) {
+		c.client = tty
+	}
+}
+
+// WithSocket makes the client talk to the tmux server listening on the socket at path instead of
+// the default server, e.g. a private server started by a test.
+func WithSocket(path string) Option {
+	return func(c *Client) {
+		c.socket = path
+	}
+}
+
+// WithExecutor runs the client's tmux commands, control mode included, with e instead of the
+// default executor. It has no effect on a client with a runner of its own.
+func WithExecutor(e *executor.Executor) Option {
+	return func(c *Client) {
+		if _, ok := c.runner.(execRunner); ok {
+			c.runner = execRunner{executor: e}
+		}
+	}
+}
+
+// WithRunner runs the client's tmux commands with runner instead of the tmux binary.
+func WithRunner(runner Runner) Option {
+	return func(c *Client) {
+		c.runner = runner
+	}
+}
 
 // New returns a new tmux client.
-func New() *Client {
-	return &Client{}
+func New(options ...Option) *Client {
+	c := &Client{runner: execRunner{}}
+	for _, option := range options {
+		option(c)
+	}
+	c.version = sync.OnceValues(c.queryVersion)
+	return c
+}
+
+// With returns a copy of the client with options applied, e.g. to count a lister's commands with
+// an executor of its own.
+func (c *Client) With(options ...Option) *Client {
+	clone := *c
+	for _, option := range options {
+		option(&clone)
+	}
+	return &clone
+}
+
+// Control starts a control-mode client on the client's server with the client's executor. It fails
+// for a client whose runner is not the tmux binary, such as the model of a dry run, and with an
+// *UnsupportedError for a tmux without ControlFlags.
+func (c *Client) Control(options ...ControlOption) (*Control, error) {
+	runner, ok := c.runner.(execRunner)
+	if !ok {
+		return nil, errors.New("tmux control mode is unavailable without the tmux binary")
+	}
+	if err := c.Require(ControlFlags); err != nil {
+		return nil, err
+	}
+	return NewControl(append([]ControlOption{WithControlSocket(c.socket), withControlExecutor(runner.executor)}, options...)...)
 }
 
 // RunCmd runs a tmux command and returns its output.
 func (c *Client) RunCmd(args []string) (stdout string, err error) {
-	stdout, stderr, err := gotmux.RunCmd(args)
+	stdout, stderr, err := c.runner.Run(c.args(args))
 	if err != nil {
+		if noServer(stderr) {
+			return "", fmt.Errorf("%w: %s", ErrNoServer, strings.TrimSpace(stderr))
+		}
 		return "", fmt.Errorf("tmux command failed: %w", err)
 	}
 
@@ -29,7 +150,116 @@ func (c *Client) RunCmd(args []string) (stdout string, err error) {
 	return stdout, nil
 }
 
-// Attach attaches to the named session, creating it if it doesn't exist.
+// noServer reports whether tmux failed because no server is listening on its socket.
+func noServer(stderr string) bool {
+	return strings.Contains(stderr, "no server running") ||
+		strings.Contains(stderr, "error connecting to")
+}
+
+// AttachOrSwitch attaches to the named session, or switches the current client to it when running
+// inside tmux.
 func (c *Client) AttachOrSwitch(name string) error {
-	return (&gotmux.Session{Name: name}).AttachSession()
+	if c.client != "" {
+		return c.SwitchClient(c.client, "="+name)
+	}
+	if gotmux.IsInsideTmux() {
+		return c.runner.Exec(c.args([]string{"switch-client", "-t", "=" + name}))
+	}
+	return c.runner.Exec(c.args([]string{"attach-session", "-t", "=" + name}))
+}
+
+// args prefixes a tmux command with the global flags selecting the server.
+func (c *Client) args(args []string) []string {
+	if c.socket == "" {
+		return args
+	}
+	return append([]string{"-S", c.socket}, args...)
+}
+
+// ClientTTY returns the tty of the current client, or "" when not running inside tmux.
+func (c *Client) ClientTTY() string {
+	if c.client != "" {
+		return c.client
+	}
+	return c.displayMessage("#{client_tty}")
+}
+
+// CurrentSession returns the name of the current client's session, or "" when not running inside
+// tmux.
+func (c *Client) CurrentSession() string {
+	return c.displayMessage("#{session_name}")
+}
+
+// LastSession returns the name of the session the current client was attached to before its
+// current one, or "" if there is none or treemux is not running inside tmux.
+func (c *Client) LastSession() string {
+	return c.displayMessage("#{client_last_session}")
+}
+
+// displayMessage expands a format for the client. It returns "" when there is no client, i.e. none
+// was given and treemux is not running inside tmux, or when the format cannot be expanded.
+func (c *Client) displayMessage(format string) string {
+	if c.client == "" && !gotmux.IsInsideTmux() {
+		return ""
+	}
+	args := []string{"display-message", "-p"}
+	if c.client != "" {
+		args = append(args, "-c", c.client)
+	}
+	output, err := c.RunCmd(append(args, format))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
+
+// SwitchClient switches the client with the given tty to target.
+func (c *Client) SwitchClient(client, target string) error {
+	_, err := c.RunCmd([]string{"switch-client", "-c", client, "-t", target})
+	return err
+}
+
+// CapturePane returns the visible contents of target's active pane.
+func (c *Client) CapturePane(target string) (string, error) {
+	return c.RunCmd([]string{"capture-pane", "-p", "-t", target})
+}
+
+// HasSession reports whether a session with the exact name exists.
+func (c *Client) HasSession(name string) bool {
+	_, err := c.RunCmd([]string{"has-session", "-t", "=" + name})
+	return err == nil
+}
+
+// NewSession creates a detached session rooted at dir.
+func (c *Client) NewSession(name, dir string) error {
+	_, err := c.RunCmd([]string{"new-session", "-d", "-s", name, "-c", dir})
+	return err
+}
+
+// NewWindow creates a background window in the session rooted at dir.
+func (c *Client) NewWindow(session, name, dir string) error {
+	_, err := c.RunCmd([]string{"new-window", "-d", "-t", "=" + session + ":", "-n", name, "-c", dir})
+	return err
+}
+
+// SendKeys types command into the named window of the session and presses Enter.
+func (c *Client) SendKeys(session, window, command string) error {
+	_, err := c.RunCmd([]string{"send-keys", "-t", "=" + session + ":" + window, command, "Enter"})
+	return err
+}
+
+// SessionName converts s into a valid tmux session name. tmux reserves '.' and ':' for target
+// syntax, so they are replaced.
+func SessionName(s string) string {
+	return strings.NewReplacer(".", "_", ":", "_").Replace(strings.TrimSpace(s))
+}
+
+// Quote quotes s as a single word for both a POSIX shell and the tmux command parser, which share
+// single-quote syntax. Words made only of safe characters are left alone so that command lines
+// stay readable.
+func Quote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:@%+,") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
 }
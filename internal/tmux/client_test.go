@@ -0,0 +1,110 @@
+package tmux_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/ian-howell/treemux/internal/executor"
+	"github.com/ian-howell/treemux/internal/tmux"
+	"github.com/ian-howell/treemux/internal/tmuxfake"
+	"github.com/ian-howell/treemux/internal/tmuxtest"
+)
+
+func TestClient(t *testing.T) {
+	server := tmuxtest.NewServer(t)
+	dir := t.TempDir()
+	client := server.Client()
+
+	if client.HasSession("work") {
+		t.Fatal("expected no session on a fresh server")
+	}
+	if err := client.NewSession("work", dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.NewSession("notes", dir); err != nil {
+		t.Fatal(err)
+	}
+	if !client.HasSession("work") || client.HasSession("wor") {
+		t.Fatal("expected HasSession to match exact names only")
+	}
+	if err := client.NewWindow("work", "logs", dir); err != nil {
+		t.Fatal(err)
+	}
+	if windows := server.Run("list-windows", "-t", "=work", "-F", "#{window_name}"); windows != "sh\nlogs" {
+		t.Fatalf("expected the logs window after the first, got %q", windows)
+	}
+	if sessions := server.Sessions(); !slices.Equal(sessions, []string{"notes", "work"}) {
+		t.Fatalf("expected notes and work, got %v", sessions)
+	}
+
+	// Attaching on behalf of a client switches that client, as from a popup.
+	name := server.AttachClient("work")
+	attached := server.Client(tmux.WithClient(name))
+	if got := attached.CurrentSession(); got != "work" {
+		t.Fatalf("expected the client on work, got %q", got)
+	}
+	if err := attached.AttachOrSwitch("notes"); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.ClientSession(name); got != "notes" {
+		t.Fatalf("expected the client switched to notes, got %q", got)
+	}
+	if got := attached.LastSession(); got != "work" {
+		t.Fatalf("expected work as the last session, got %q", got)
+	}
+	if err := attached.AttachOrSwitch("missing"); err == nil {
+		t.Fatal("expected switching to a missing session to fail")
+	}
+}
+
+func TestNoServer(t *testing.T) {
+	server := tmuxtest.NewServer(t)
+	client := server.Client()
+	server.Run("kill-server")
+	if _, err := client.RunCmd([]string{"list-sessions"}); !errors.Is(err, tmux.ErrNoServer) {
+		t.Fatalf("expected ErrNoServer, got %v", err)
+	}
+}
+
+func TestAttachOrSwitchMatchesExactly(t *testing.T) {
+	for _, inside := range []bool{false, true} {
+		server := &tmuxfake.Server{}
+		server.AddSession("api-2", "/src/api-2")
+		if inside {
+			t.Setenv("TMUX", "/tmp/tmux-1000/default,1,0")
+			server.AddClient(tmuxfake.Client{TTY: "/dev/pts/1", Session: "api-2"})
+			server.Current = "/dev/pts/1"
+		} else {
+			t.Setenv("TMUX", "")
+		}
+		// Without an exact match, tmux would take "api" as a prefix of api-2.
+		if err := tmux.New(tmux.WithRunner(server)).AttachOrSwitch("api"); err == nil {
+			t.Fatalf("inside tmux %v: expected no session named api, got calls %v", inside, server.Calls())
+		}
+	}
+}
+
+func TestClientControl(t *testing.T) {
+	server := tmuxtest.NewServer(t)
+	server.Run("new-session", "-d", "-s", "work")
+
+	// Control mode connects to the client's server and runs tmux with the client's executor.
+	e := executor.New()
+	control, err := server.Client(tmux.WithExecutor(e)).Control()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := control.RunCmd([]string{"display-message", "-p", "#{session_name}"}); err != nil || output != "work\n" {
+		t.Fatalf("expected work, got %q, %v", output, err)
+	}
+	control.Close()
+	// tmux -V checks that control mode can attach with -f, then the control client runs.
+	if got := e.Stats().Commands; got != 2 {
+		t.Fatalf("expected the version query and the control client counted by the executor, got %d commands", got)
+	}
+
+	if _, err := tmux.New(tmux.WithRunner(&tmuxfake.Server{})).Control(); err == nil {
+		t.Fatal("expected no control mode without the tmux binary")
+	}
+}
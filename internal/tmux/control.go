@@ -0,0 +1,349 @@
+package tmux
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ian-howell/treemux/internal/executor"
+)
+
+// ErrControlClosed is returned by commands sent after the control connection has ended.
+var ErrControlClosed = errors.New("tmux control connection closed")
+
+// notificationBuffer is how many notifications a subscriber may fall behind before further ones
+// are dropped.
+const notificationBuffer = 16
+
+// Control is a tmux control-mode client. Unlike Client, which forks a tmux process per command, it
+// keeps a single `tmux -C` connection open and sends every command over it.
+//
+// The connection attaches read-only to a session without affecting its size or receiving pane
+// output. Note that tmux counts it as an attached client of that session.
+type Control struct {
+	process *executor.Process
+	stdin   io.WriteCloser
+
+	// mu serializes writes so that pending is queued in the same order tmux answers commands.
+	mu      sync.Mutex
+	pending []chan controlResult
+	closed  bool
+	err     error
+
+	subscribersMu sync.Mutex
+	subscribers   []subscriber
+
+	// done is closed once the connection has ended.
+	done chan struct{}
+}
+
+// Notification is an asynchronous message from tmux, such as "sessions-changed".
+type Notification struct {
+	// Name is the notification name without its leading '%'.
+	Name string
+
+	// Args are the notification's space-separated arguments.
+	Args []string
+}
+
+// controlResult is the output of a single command.
+type controlResult struct {
+	output []string
+	err    error
+}
+
+// subscriber receives notifications with one of the given names, or all of them if names is empty.
+type subscriber struct {
+	names []string
+	ch    chan Notification
+}
+
+// ControlOption configures a control-mode client.
+type ControlOption func(*controlConfig)
+
+// controlConfig holds the control-mode client options.
+type controlConfig struct {
+	socket   string
+	session  string
+	executor *executor.Executor
+}
+
+// WithControlSocket connects to the tmux server listening on the socket at path instead of the
+// default server.
+func WithControlSocket(path string) ControlOption {
+	return func(c *controlConfig) {
+		c.socket = path
+	}
+}
+
+// WithControlSession attaches the client to the named session rather than the most recently used
+// one.
+func WithControlSession(name string) ControlOption {
+	return func(c *controlConfig) {
+		c.session = name
+	}
+}
+
+// withControlExecutor runs tmux with e instead of the default executor.
+func withControlExecutor(e *executor.Executor) ControlOption {
+	return func(c *controlConfig) {
+		c.executor = e
+	}
+}
+
+// NewControl starts a control-mode client attached to the most recently used session. It fails if
+// the server has no sessions.
+func NewControl(options ...ControlOption) (*Control, error) {
+	var config controlConfig
+	for _, option := range options {
+		option(&config)
+	}
+	var args []string
+	if config.socket != "" {
+		args = append(args, "-S", config.socket)
+	}
+	args = append(args, "-C", "attach-session", "-f", "no-output,read-only,ignore-size")
+	if config.session != "" {
+		args = append(args, "-t", "="+config.session)
+	}
+
+	if config.executor == nil {
+		config.executor = executor.Default()
+	}
+	process, err := config.executor.Start(executor.Command{Name: "tmux", Args: args})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start tmux control mode: %w", err)
+	}
+	c := &Control{
+		process: process,
+		stdin:   process.Stdin,
+		done:    make(chan struct{}),
+	}
+
+	// The attach command is answered like any other, so it is the first pending command.
+	ready := make(chan controlResult, 1)
+	c.pending = append(c.pending, ready)
+	go c.read(process.Stdout)
+	if result := <-ready; result.err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to attach tmux control client: %w", result.err)
+	}
+	return c, nil
+}
+
+// RunCmd runs a tmux command over the control connection and returns its output.
+func (c *Control) RunCmd(args []string) (string, error) {
+	outputs, err := c.RunCmds([][]string{args})
+	if err != nil {
+		return "", err
+	}
+	return outputs[0], nil
+}
+
+// RunCmds sends all commands before waiting for any of them, so that a batch costs a single round
+// trip. It returns each command's output, or the first error.
+func (c *Control) RunCmds(cmds [][]string) ([]string, error) {
+	waiters, err := c.send(cmds)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]string, len(cmds))
+	var firstErr error
+	for i, waiter := range waiters {
+		result := <-waiter
+		if result.err != nil {
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			continue
+		}
+		if len(result.output) > 0 {
+			outputs[i] = strings.Join(result.output, "\n") + "\n"
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return outputs, nil
+}
+
+// send writes the commands and queues a waiter for each of them.
+func (c *Control) send(cmds [][]string) ([]chan controlResult, error) {
+	var lines strings.Builder
+	for _, args := range cmds {
+		line, err := commandLine(args)
+		if err != nil {
+			return nil, err
+		}
+		lines.WriteString(line)
+		lines.WriteByte('\n')
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, c.closedErr()
+	}
+	waiters := make([]chan controlResult, len(cmds))
+	for i := range waiters {
+		waiters[i] = make(chan controlResult, 1)
+	}
+	c.pending = append(c.pending, waiters...)
+	if _, err := io.WriteString(c.stdin, lines.String()); err != nil {
+		c.pending = c.pending[:len(c.pending)-len(waiters)]
+		return nil, fmt.Errorf("failed to send tmux command: %w", err)
+	}
+	return waiters, nil
+}
+
+// Subscribe returns a channel receiving the notifications with the given names, e.g.
+// "sessions-changed", "session-renamed" or "window-add", or every notification if no names are
+// given. Notifications are dropped while the channel is full. The channel is closed when the
+// connection ends.
+func (c *Control) Subscribe(names ...string) <-chan Notification {
+	ch := make(chan Notification, notificationBuffer)
+	c.subscribersMu.Lock()
+	defer c.subscribersMu.Unlock()
+	select {
+	case <-c.done:
+		close(ch)
+	default:
+		c.subscribers = append(c.subscribers, subscriber{names: names, ch: ch})
+	}
+	return ch
+}
+
+// Done is closed once the connection has ended, e.g. because the tmux server exited.
+func (c *Control) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close ends the connection and waits for tmux to exit.
+func (c *Control) Close() error {
+	c.mu.Lock()
+	c.stdin.Close()
+	c.mu.Unlock()
+	<-c.done
+	return nil
+}
+
+// read parses command blocks and notifications until tmux exits.
+func (c *Control) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var (
+		// block holds the number of the command whose output is being read, or "" outside of one.
+		block  string
+		output []string
+	)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if block != "" {
+			if fields := strings.Fields(line); len(fields) >= 3 && fields[2] == block &&
+				(fields[0] == "%end" || fields[0] == "%error") {
+				result := controlResult{output: output}
+				if fields[0] == "%error" {
+					result = controlResult{err: fmt.Errorf("tmux error: %s", strings.Join(output, "\n"))}
+				}
+				c.complete(result)
+				block, output = "", nil
+				continue
+			}
+			output = append(output, line)
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) >= 3 && fields[0] == "%begin" {
+			block = fields[2]
+			continue
+		}
+		if name, ok := strings.CutPrefix(line, "%"); ok {
+			fields := strings.Fields(name)
+			if len(fields) == 0 {
+				continue
+			}
+			if fields[0] == "exit" {
+				break
+			}
+			c.notify(Notification{Name: fields[0], Args: fields[1:]})
+		}
+	}
+	// Drain anything after %exit so that tmux is never blocked writing before it exits.
+	_, _ = io.Copy(io.Discard, stdout)
+	c.shutdown()
+}
+
+// complete hands result to the oldest pending command.
+func (c *Control) complete(result controlResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) == 0 {
+		return
+	}
+	c.pending[0] <- result
+	c.pending = c.pending[1:]
+}
+
+// notify delivers n to matching subscribers without blocking.
+func (c *Control) notify(n Notification) {
+	c.subscribersMu.Lock()
+	defer c.subscribersMu.Unlock()
+	for _, s := range c.subscribers {
+		if len(s.names) > 0 && !slices.Contains(s.names, n.Name) {
+			continue
+		}
+		select {
+		case s.ch <- n:
+		default:
+		}
+	}
+}
+
+// shutdown fails pending commands and closes subscriptions once tmux has exited.
+func (c *Control) shutdown() {
+	c.mu.Lock()
+	c.stdin.Close()
+	stderr, waitErr := c.process.Wait()
+	c.closed = true
+	if stderr := strings.TrimSpace(stderr); stderr != "" {
+		c.err = errors.New(stderr)
+	} else if waitErr != nil {
+		c.err = waitErr
+	}
+	for _, waiter := range c.pending {
+		waiter <- controlResult{err: c.closedErr()}
+	}
+	c.pending = nil
+	c.mu.Unlock()
+
+	c.subscribersMu.Lock()
+	close(c.done)
+	for _, s := range c.subscribers {
+		close(s.ch)
+	}
+	c.subscribers = nil
+	c.subscribersMu.Unlock()
+}
+
+// closedErr describes why the connection ended. It must be called with mu held.
+func (c *Control) closedErr() error {
+	if c.err != nil {
+		return fmt.Errorf("%w: %w", ErrControlClosed, c.err)
+	}
+	return ErrControlClosed
+}
+
+// commandLine quotes args into a single tmux command line.
+func commandLine(args []string) (string, error) {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if strings.ContainsAny(arg, "\r\n") {
+			return "", fmt.Errorf("tmux argument %q contains a newline", arg)
+		}
+		quoted[i] = Quote(arg)
+	}
+	return strings.Join(quoted, " "), nil
+}
@@ -0,0 +1,76 @@
+package tmux
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestControl(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux is not installed")
+	}
+	// Unix socket paths are limited in length, so avoid the long per-test temporary directory.
+	dir, err := os.MkdirTemp("", "treemux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "sock")
+	tmux := func(args ...string) {
+		t.Helper()
+		if output, err := exec.Command("tmux", append([]string{"-S", socket, "-f", "/dev/null"}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("tmux %v: %v: %s", args, err, output)
+		}
+	}
+	tmux("new-session", "-d", "-s", "one")
+	t.Cleanup(func() { exec.Command("tmux", "-S", socket, "kill-server").Run() })
+
+	control, err := NewControl(WithControlSocket(socket))
+	if err != nil {
+		t.Fatalf("NewControl: %v", err)
+	}
+	defer control.Close()
+
+	if output, err := control.RunCmd([]string{"display-message", "-p", "it's #{session_name}"}); err != nil || output != "it's one\n" {
+		t.Fatalf("expected quoted output, got %q, %v", output, err)
+	}
+	if _, err := control.RunCmd([]string{"has-session", "-t", "=missing"}); err == nil {
+		t.Fatal("expected an error for a missing session")
+	}
+
+	changed := control.Subscribe("sessions-changed")
+	tmux("new-session", "-d", "-s", "two")
+	select {
+	case n := <-changed:
+		if n.Name != "sessions-changed" {
+			t.Fatalf("expected sessions-changed, got %+v", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a sessions-changed notification")
+	}
+
+	outputs, err := control.RunCmds([][]string{
+		{"display-message", "-p", "-t", "=one:", "#{session_name}"},
+		{"display-message", "-p", "-t", "=two:", "#{session_name}"},
+	})
+	if err != nil || len(outputs) != 2 || outputs[0] != "one\n" || outputs[1] != "two\n" {
+		t.Fatalf("expected batched outputs, got %q, %v", outputs, err)
+	}
+
+	tmux("kill-server")
+	select {
+	case <-control.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the connection to end with the server")
+	}
+	// Notifications still buffered are delivered before the subscription is closed.
+	for range changed {
+	}
+	if _, err := control.RunCmd([]string{"list-sessions"}); !errors.Is(err, ErrControlClosed) {
+		t.Fatalf("expected ErrControlClosed, got %v", err)
+	}
+}
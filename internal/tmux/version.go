@@ -0,0 +1,157 @@
+package tmux
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a tmux version, as reported by `tmux -V`.
+type Version struct {
+	Major, Minor int
+
+	// Patch is the letter of a patch release, e.g. "a" in 3.3a.
+	Patch string
+
+	// Next marks a development build working towards Major.Minor, e.g. "next-3.5". It has most of
+	// the features of that release.
+	Next bool
+
+	// Dev marks a build that reports no version number, such as "master" or OpenBSD's
+	// "openbsd-7.4". It is taken to be newer than every release.
+	Dev bool
+
+	// raw is the version as tmux reported it.
+	raw string
+}
+
+// ParseVersion parses the output of `tmux -V`, e.g. "tmux 3.3a", "tmux next-3.5", "tmux 3.2-rc2"
+// or "tmux master".
+func ParseVersion(output string) (Version, error) {
+	raw := strings.TrimSpace(output)
+	s, ok := strings.CutPrefix(raw, "tmux ")
+	if !ok {
+		return Version{}, fmt.Errorf("unexpected tmux version %q", raw)
+	}
+	v := Version{raw: s}
+	if s == "master" || strings.HasPrefix(s, "openbsd-") {
+		v.Dev = true
+		return v, nil
+	}
+	s, v.Next = strings.CutPrefix(s, "next-")
+	// Release candidates are versioned like the release, e.g. 3.2-rc2.
+	s, _, _ = strings.Cut(s, "-")
+
+	majorPart, minorPart, ok := strings.Cut(s, ".")
+	if !ok {
+		return Version{}, fmt.Errorf("unexpected tmux version %q", raw)
+	}
+	digits := strings.IndexFunc(minorPart, func(r rune) bool { return r < '0' || r > '9' })
+	if digits < 0 {
+		digits = len(minorPart)
+	}
+	minorPart, v.Patch = minorPart[:digits], minorPart[digits:]
+	var err error
+	if v.Major, err = strconv.Atoi(majorPart); err != nil {
+		return Version{}, fmt.Errorf("unexpected tmux version %q", raw)
+	}
+	if v.Minor, err = strconv.Atoi(minorPart); err != nil {
+		return Version{}, fmt.Errorf("unexpected tmux version %q", raw)
+	}
+	return v, nil
+}
+
+// String returns the version as tmux reports it.
+func (v Version) String() string {
+	if v.raw != "" {
+		return v.raw
+	}
+	if v.Next {
+		return fmt.Sprintf("next-%d.%d", v.Major, v.Minor)
+	}
+	return fmt.Sprintf("%d.%d%s", v.Major, v.Minor, v.Patch)
+}
+
+// AtLeast reports whether v is release major.minor or newer.
+func (v Version) AtLeast(major, minor int) bool {
+	if v.Dev {
+		return true
+	}
+	return v.Major > major || v.Major == major && v.Minor >= minor
+}
+
+// Capability is a tmux feature that treemux relies on, and the release that introduced it.
+type Capability struct {
+	// Name describes the feature, e.g. "display-popup".
+	Name string
+
+	Major, Minor int
+}
+
+// The capabilities treemux gates on.
+var (
+	// LastAttached is the session_last_attached format, which ranks sessions by recent use.
+	LastAttached = Capability{Name: "session_last_attached", Major: 2, Minor: 1}
+
+	// Popup is display-popup, which `treemux popup` opens the picker in.
+	Popup = Capability{Name: "display-popup", Major: 3, Minor: 2}
+
+	// PopupStyle is the -b (border lines) and -T (title) flags of display-popup.
+	PopupStyle = Capability{Name: "display-popup -b and -T", Major: 3, Minor: 3}
+
+	// ConfigBlocks is the tmux.conf syntax of the snippet `treemux init tmux` installs: indexed
+	// hooks such as client-session-changed[73], and commands in { } blocks.
+	ConfigBlocks = Capability{Name: "indexed hooks and { } command blocks", Major: 3, Minor: 0}
+
+	// ControlFlags is the -f flag of attach-session, with which control mode attaches read-only,
+	// without pane output and without resizing the session.
+	ControlFlags = Capability{Name: "attach-session -f", Major: 3, Minor: 2}
+)
+
+// Capabilities lists every capability, oldest first.
+var Capabilities = []Capability{LastAttached, ConfigBlocks, Popup, ControlFlags, PopupStyle}
+
+// String describes the capability and the release that introduced it.
+func (c Capability) String() string {
+	return fmt.Sprintf("%s (tmux %d.%d)", c.Name, c.Major, c.Minor)
+}
+
+// UnsupportedError reports a capability missing from the installed tmux.
+type UnsupportedError struct {
+	Capability Capability
+	Version    Version
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("tmux %s does not support %s (added in tmux %d.%d)",
+		e.Version, e.Capability.Name, e.Capability.Major, e.Capability.Minor)
+}
+
+// Version returns the version of tmux. It is asked once per client.
+func (c *Client) Version() (Version, error) {
+	return c.version()
+}
+
+// queryVersion runs `tmux -V`.
+func (c *Client) queryVersion() (Version, error) {
+	output, err := c.RunCmd([]string{"-V"})
+	if err != nil {
+		return Version{}, err
+	}
+	return ParseVersion(output)
+}
+
+// Supports reports whether tmux has the capability. A version that cannot be determined is
+// assumed to support everything, so that tmux gets to report what it cannot do.
+func (c *Client) Supports(capability Capability) bool {
+	return c.Require(capability) == nil
+}
+
+// Require returns an *UnsupportedError if tmux lacks the capability.
+func (c *Client) Require(capability Capability) error {
+	version, err := c.Version()
+	if err != nil || version.AtLeast(capability.Major, capability.Minor) {
+		return nil
+	}
+	return &UnsupportedError{Capability: capability, Version: version}
+}
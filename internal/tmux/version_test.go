@@ -0,0 +1,70 @@
+package tmux_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ian-howell/treemux/internal/tmux"
+	"github.com/ian-howell/treemux/internal/tmuxfake"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		output string
+		want   tmux.Version
+		// atLeast32 is whether the version has display-popup.
+		atLeast32 bool
+	}{
+		{"tmux 3.3a\n", tmux.Version{Major: 3, Minor: 3, Patch: "a"}, true},
+		{"tmux 3.1c", tmux.Version{Major: 3, Minor: 1, Patch: "c"}, false},
+		{"tmux 3.2", tmux.Version{Major: 3, Minor: 2}, true},
+		{"tmux 3.2-rc2", tmux.Version{Major: 3, Minor: 2}, true},
+		{"tmux 2.9a", tmux.Version{Major: 2, Minor: 9, Patch: "a"}, false},
+		{"tmux next-3.5", tmux.Version{Major: 3, Minor: 5, Next: true}, true},
+		{"tmux master", tmux.Version{Dev: true}, true},
+		{"tmux openbsd-7.4", tmux.Version{Dev: true}, true},
+	}
+	for _, tt := range tests {
+		got, err := tmux.ParseVersion(tt.output)
+		if err != nil {
+			t.Errorf("ParseVersion(%q): %v", tt.output, err)
+			continue
+		}
+		if got.Major != tt.want.Major || got.Minor != tt.want.Minor || got.Patch != tt.want.Patch ||
+			got.Next != tt.want.Next || got.Dev != tt.want.Dev {
+			t.Errorf("ParseVersion(%q) = %+v, want %+v", tt.output, got, tt.want)
+		}
+		if got.AtLeast(3, 2) != tt.atLeast32 {
+			t.Errorf("ParseVersion(%q).AtLeast(3, 2) = %t, want %t", tt.output, !tt.atLeast32, tt.atLeast32)
+		}
+	}
+
+	for _, output := range []string{"", "screen 4.9", "tmux 3", "tmux x.y"} {
+		if _, err := tmux.ParseVersion(output); err == nil {
+			t.Errorf("expected ParseVersion(%q) to fail", output)
+		}
+	}
+}
+
+func TestRequire(t *testing.T) {
+	tmuxClient := tmux.New(tmux.WithRunner(&tmuxfake.Server{Version: "3.1c"}))
+	if !tmuxClient.Supports(tmux.ConfigBlocks) {
+		t.Fatal("expected tmux 3.1c to support the tmux.conf snippet")
+	}
+	err := tmuxClient.Require(tmux.Popup)
+	unsupported, ok := errors.AsType[*tmux.UnsupportedError](err)
+	if !ok || unsupported.Capability != tmux.Popup {
+		t.Fatalf("expected tmux 3.1c to lack popups, got %v", err)
+	}
+	if want := "tmux 3.1c does not support display-popup (added in tmux 3.2)"; err.Error() != want {
+		t.Fatalf("expected %q, got %q", want, err.Error())
+	}
+
+	// A development build supports everything.
+	tmuxClient = tmux.New(tmux.WithRunner(&tmuxfake.Server{}))
+	for _, capability := range tmux.Capabilities {
+		if err := tmuxClient.Require(capability); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
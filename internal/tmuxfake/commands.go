@@ -0,0 +1,483 @@
+package tmuxfake
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// call is a parsed command.
+type call struct {
+	// values holds the flags that take a value, and bools the flags that do not.
+	values map[byte]string
+	bools  map[byte]bool
+
+	// args are the positional arguments.
+	args []string
+}
+
+// parseArgs parses tmux-style flags, where valueFlags lists the flags that take a value.
+func parseArgs(args []string, valueFlags string) call {
+	c := call{values: map[byte]string{}, bools: map[byte]bool{}}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			c.args = append(c.args, args[i+1:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			c.args = append(c.args, args[i:]...)
+			break
+		}
+		for j := 1; j < len(arg); j++ {
+			flag := arg[j]
+			if !strings.ContainsRune(valueFlags, rune(flag)) {
+				c.bools[flag] = true
+				continue
+			}
+			if j+1 < len(arg) {
+				c.values[flag] = arg[j+1:]
+			} else if i+1 < len(args) {
+				i++
+				c.values[flag] = args[i]
+			}
+			break
+		}
+	}
+	return c
+}
+
+// command is a tmux command the model understands.
+type command struct {
+	valueFlags string
+
+	// mutates is set for commands that change the server, which a dry run prints.
+	mutates bool
+
+	run func(s *Server, c call) (string, error)
+}
+
+// commands are the commands treemux issues. Flags the model has no use for are accepted and
+// ignored.
+var commands = map[string]command{
+	"-V":              {run: (*Server).version},
+	"list-sessions":   {valueFlags: "Ff", run: (*Server).listSessions},
+	"list-windows":    {valueFlags: "Fft", run: (*Server).listWindows},
+	"list-panes":      {valueFlags: "Fft", run: (*Server).listPanes},
+	"list-clients":    {valueFlags: "Ft", run: (*Server).listClients},
+	"display-message": {valueFlags: "ctFd", run: (*Server).displayMessage},
+	"has-session":     {valueFlags: "t", run: (*Server).hasSession},
+	"capture-pane":    {valueFlags: "tSEb", run: (*Server).capturePane},
+	"new-session":     {valueFlags: "scnFxyte", mutates: true, run: (*Server).newSession},
+	"new-window":      {valueFlags: "tncFe", mutates: true, run: (*Server).newWindow},
+	"split-window":    {valueFlags: "tcFle", mutates: true, run: (*Server).splitWindow},
+	"select-layout":   {valueFlags: "t", mutates: true, run: (*Server).selectLayout},
+	"select-window":   {valueFlags: "t", mutates: true, run: (*Server).selectWindow},
+	"select-pane":     {valueFlags: "tT", mutates: true, run: (*Server).selectPane},
+	"send-keys":       {valueFlags: "tN", mutates: true, run: (*Server).sendKeys},
+	"rename-session":  {valueFlags: "t", mutates: true, run: (*Server).renameSession},
+	"kill-session":    {valueFlags: "t", mutates: true, run: (*Server).killSession},
+	"kill-window":     {valueFlags: "t", mutates: true, run: (*Server).killWindow},
+	"switch-client":   {valueFlags: "ctT", mutates: true, run: (*Server).switchClient},
+	"attach-session":  {valueFlags: "ctf", mutates: true, run: (*Server).attachSession},
+	"display-popup":   {valueFlags: "cdhwxyTbsSe", mutates: true, run: (*Server).ignore},
+}
+
+func (s *Server) version(call) (string, error) {
+	if s.Version == "" {
+		return "tmux master\n", nil
+	}
+	return "tmux " + s.Version + "\n", nil
+}
+
+func (s *Server) listSessions(c call) (string, error) {
+	var lines []string
+	for _, session := range s.sessions {
+		lines = append(lines, s.expand(c.values['F'], "#{session_name}: #{session_windows} windows", s.sessionVars(session)))
+	}
+	return joinLines(lines), nil
+}
+
+func (s *Server) listWindows(c call) (string, error) {
+	sessions := s.sessions
+	if !c.bools['a'] {
+		session, _, _, err := s.resolve(c.values['t'])
+		if err != nil {
+			return "", err
+		}
+		sessions = []*Session{session}
+	}
+	var lines []string
+	for _, session := range sessions {
+		for _, window := range session.Windows {
+			lines = append(lines, s.expand(c.values['F'], "#{window_index}: #{window_name}", s.paneVars(session, window, activePane(window))))
+		}
+	}
+	return joinLines(lines), nil
+}
+
+func (s *Server) listPanes(c call) (string, error) {
+	type target struct {
+		session *Session
+		window  *Window
+	}
+	var targets []target
+	switch {
+	case c.bools['a']:
+		for _, session := range s.sessions {
+			for _, window := range session.Windows {
+				targets = append(targets, target{session, window})
+			}
+		}
+	default:
+		session, window, _, err := s.resolve(c.values['t'])
+		if err != nil {
+			return "", err
+		}
+		if c.bools['s'] {
+			for _, window := range session.Windows {
+				targets = append(targets, target{session, window})
+			}
+		} else {
+			targets = append(targets, target{session, window})
+		}
+	}
+	var lines []string
+	for _, t := range targets {
+		for _, pane := range t.window.Panes {
+			lines = append(lines, s.expand(c.values['F'], "#{pane_index}: #{pane_current_command}", s.paneVars(t.session, t.window, pane)))
+		}
+	}
+	return joinLines(lines), nil
+}
+
+func (s *Server) listClients(c call) (string, error) {
+	var lines []string
+	for _, client := range s.clients {
+		lines = append(lines, s.expand(c.values['F'], "#{client_name}: #{client_session}", s.clientVars(client)))
+	}
+	return joinLines(lines), nil
+}
+
+func (s *Server) displayMessage(c call) (string, error) {
+	client, err := s.client(c.values['c'])
+	if err != nil && (c.values['c'] != "" || c.values['t'] == "") {
+		return "", err
+	}
+	target := c.values['t']
+	if target == "" {
+		target = client.Session
+	}
+	session, window, pane, err := s.resolve(target)
+	if err != nil {
+		return "", err
+	}
+	vars := s.paneVars(session, window, pane)
+	if client != nil {
+		for name, value := range s.clientVars(client) {
+			vars[name] = value
+		}
+	}
+	if !c.bools['p'] {
+		return "", nil
+	}
+	return s.expand(strings.Join(c.args, " "), "", vars) + "\n", nil
+}
+
+func (s *Server) hasSession(c call) (string, error) {
+	_, _, _, err := s.resolve(c.values['t'])
+	return "", err
+}
+
+func (s *Server) capturePane(c call) (string, error) {
+	_, _, pane, err := s.resolve(c.values['t'])
+	if err != nil {
+		return "", err
+	}
+	return joinLines(pane.Content), nil
+}
+
+func (s *Server) newSession(c call) (string, error) {
+	name := c.values['s']
+	if name == "" {
+		name = strconv.Itoa(len(s.sessions))
+	}
+	if strings.ContainsAny(name, ".:") {
+		return "", fmt.Errorf("invalid session: %s", name)
+	}
+	if s.session(name) != nil {
+		return "", fmt.Errorf("duplicate session: %s", name)
+	}
+	session := &Session{Name: name, Path: c.values['c']}
+	s.sessions = append(s.sessions, session)
+	windowName := c.values['n']
+	if windowName == "" {
+		windowName = "sh"
+	}
+	window := s.addWindow(session, 0, windowName, session.Path)
+	window.Active = true
+	if !c.bools['d'] {
+		if err := s.attach(name); err != nil {
+			return "", err
+		}
+	}
+	return s.printed(c, "#{session_name}:", s.paneVars(session, window, window.Panes[0])), nil
+}
+
+func (s *Server) newWindow(c call) (string, error) {
+	target := c.values['t']
+	sessionName, index, hasIndex := strings.Cut(target, ":")
+	session, _, _, err := s.resolve(sessionName)
+	if err != nil {
+		return "", err
+	}
+	windowIndex := nextIndex(session)
+	if hasIndex && index != "" {
+		if windowIndex, err = strconv.Atoi(index); err != nil {
+			return "", fmt.Errorf("invalid window index: %s", index)
+		}
+	}
+	if i := slices.IndexFunc(session.Windows, func(w *Window) bool { return w.Index == windowIndex }); i >= 0 {
+		if !c.bools['k'] {
+			return "", fmt.Errorf("index in use: %d", windowIndex)
+		}
+		session.Windows = slices.Delete(session.Windows, i, i+1)
+	}
+	name := c.values['n']
+	if name == "" {
+		name = "sh"
+	}
+	path := c.values['c']
+	if path == "" {
+		path = session.Path
+	}
+	window := s.addWindow(session, windowIndex, name, path)
+	if !c.bools['d'] || len(session.Windows) == 1 {
+		setActiveWindow(session, window)
+	}
+	return s.printed(c, "#{session_name}:#{window_index}.#{pane_index}", s.paneVars(session, window, window.Panes[0])), nil
+}
+
+func (s *Server) splitWindow(c call) (string, error) {
+	session, window, target, err := s.resolve(c.values['t'])
+	if err != nil {
+		return "", err
+	}
+	path := c.values['c']
+	if path == "" {
+		path = target.Path
+	}
+	s.nextID++
+	pane := &Pane{ID: s.nextID, Path: path}
+	i := slices.Index(window.Panes, target)
+	window.Panes = slices.Insert(window.Panes, i+1, pane)
+	for index, p := range window.Panes {
+		p.Index = index
+	}
+	if !c.bools['d'] {
+		setActivePane(window, pane)
+	}
+	return s.printed(c, "#{session_name}:#{window_index}.#{pane_index}", s.paneVars(session, window, pane)), nil
+}
+
+func (s *Server) selectLayout(c call) (string, error) {
+	_, window, _, err := s.resolve(c.values['t'])
+	if err != nil {
+		return "", err
+	}
+	if len(c.args) > 0 {
+		window.Layout = c.args[0]
+	}
+	return "", nil
+}
+
+func (s *Server) selectWindow(c call) (string, error) {
+	session, window, _, err := s.resolve(c.values['t'])
+	if err != nil {
+		return "", err
+	}
+	setActiveWindow(session, window)
+	return "", nil
+}
+
+func (s *Server) selectPane(c call) (string, error) {
+	_, window, pane, err := s.resolve(c.values['t'])
+	if err != nil {
+		return "", err
+	}
+	setActivePane(window, pane)
+	return "", nil
+}
+
+func (s *Server) sendKeys(c call) (string, error) {
+	_, _, pane, err := s.resolve(c.values['t'])
+	if err != nil {
+		return "", err
+	}
+	var typed []string
+	for _, key := range c.args {
+		if key != "Enter" && key != "C-m" {
+			typed = append(typed, key)
+			continue
+		}
+		line := strings.Join(typed, "")
+		pane.Content = append(pane.Content, line)
+		// The pane's shell runs the line, so its first word becomes the foreground command.
+		if fields := strings.Fields(line); len(fields) > 0 {
+			pane.Command = fields[0]
+		}
+		typed = nil
+	}
+	return "", nil
+}
+
+func (s *Server) renameSession(c call) (string, error) {
+	session, _, _, err := s.resolve(c.values['t'])
+	if err != nil {
+		return "", err
+	}
+	if len(c.args) == 0 {
+		return "", fmt.Errorf("missing new name")
+	}
+	if s.session(c.args[0]) != nil {
+		return "", fmt.Errorf("duplicate session: %s", c.args[0])
+	}
+	for _, client := range s.clients {
+		if client.Session == session.Name {
+			client.Session = c.args[0]
+		}
+	}
+	session.Name = c.args[0]
+	return "", nil
+}
+
+func (s *Server) killSession(c call) (string, error) {
+	session, _, _, err := s.resolve(c.values['t'])
+	if err != nil {
+		return "", err
+	}
+	s.removeSession(session)
+	return "", nil
+}
+
+func (s *Server) killWindow(c call) (string, error) {
+	session, window, _, err := s.resolve(c.values['t'])
+	if err != nil {
+		return "", err
+	}
+	session.Windows = slices.DeleteFunc(session.Windows, func(w *Window) bool { return w == window })
+	if len(session.Windows) == 0 {
+		s.removeSession(session)
+	} else if window.Active {
+		session.Windows[0].Active = true
+	}
+	return "", nil
+}
+
+func (s *Server) switchClient(c call) (string, error) {
+	client, err := s.client(c.values['c'])
+	if err != nil {
+		return "", err
+	}
+	session, window, _, err := s.resolve(c.values['t'])
+	if err != nil {
+		return "", err
+	}
+	setActiveWindow(session, window)
+	s.moveClient(client, session)
+	return "", nil
+}
+
+func (s *Server) attachSession(c call) (string, error) {
+	target := c.values['t']
+	if target == "" && len(s.sessions) > 0 {
+		target = s.sessions[len(s.sessions)-1].Name
+	}
+	return "", s.attach(target)
+}
+
+func (s *Server) ignore(call) (string, error) {
+	return "", nil
+}
+
+// attach attaches a new client to target, as attach-session from a terminal does.
+func (s *Server) attach(target string) error {
+	session, window, _, err := s.resolve(target)
+	if err != nil {
+		return err
+	}
+	setActiveWindow(session, window)
+	client := &Client{Name: fmt.Sprintf("client-%d", len(s.clients)+1), TTY: fmt.Sprintf("/dev/pts/fake%d", len(s.clients)+1)}
+	s.clients = append(s.clients, client)
+	s.moveClient(client, session)
+	return nil
+}
+
+// moveClient attaches client to session, remembering the session it leaves.
+func (s *Server) moveClient(client *Client, session *Session) {
+	if client.Session != session.Name {
+		client.LastSession = client.Session
+	}
+	client.Session = session.Name
+	s.clock++
+	session.LastAttached = s.clock
+}
+
+// removeSession destroys session, detaching its clients.
+func (s *Server) removeSession(session *Session) {
+	s.sessions = slices.DeleteFunc(s.sessions, func(other *Session) bool { return other == session })
+	s.clients = slices.DeleteFunc(s.clients, func(client *Client) bool { return client.Session == session.Name })
+}
+
+// addWindow appends a window with a single pane to session.
+func (s *Server) addWindow(session *Session, index int, name, path string) *Window {
+	s.nextID++
+	window := &Window{ID: s.nextID, Index: index, Name: name}
+	s.nextID++
+	window.Panes = []*Pane{{ID: s.nextID, Path: path, Command: "sh", Active: true}}
+	session.Windows = append(session.Windows, window)
+	slices.SortFunc(session.Windows, func(a, b *Window) int { return a.Index - b.Index })
+	return window
+}
+
+// printed returns the output of a command creating something, which is only printed with -P.
+func (s *Server) printed(c call, defaultFormat string, vars map[string]string) string {
+	if !c.bools['P'] {
+		return ""
+	}
+	return s.expand(c.values['F'], defaultFormat, vars) + "\n"
+}
+
+// nextIndex returns the first free window index in session.
+func nextIndex(session *Session) int {
+	index := 0
+	for _, window := range session.Windows {
+		if window.Index >= index {
+			index = window.Index + 1
+		}
+	}
+	return index
+}
+
+// setActiveWindow makes window the active window of session.
+func setActiveWindow(session *Session, window *Window) {
+	for _, w := range session.Windows {
+		w.Active = w == window
+	}
+}
+
+// setActivePane makes pane the active pane of window.
+func setActivePane(window *Window, pane *Pane) {
+	for _, p := range window.Panes {
+		p.Active = p == pane
+	}
+}
+
+// joinLines joins output lines, each terminated by a newline as tmux prints them.
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
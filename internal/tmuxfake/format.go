@@ -0,0 +1,270 @@
+package tmuxfake
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// sessionVars returns the format variables of session.
+func (s *Server) sessionVars(session *Session) map[string]string {
+	attached := 0
+	for _, client := range s.clients {
+		if client.Session == session.Name {
+			attached++
+		}
+	}
+	// Like tmux, sessions that were never attached have no attach time.
+	lastAttached := ""
+	if session.LastAttached != 0 {
+		lastAttached = strconv.FormatInt(session.LastAttached, 10)
+	}
+	return map[string]string{
+		"session_name":          session.Name,
+		"session_path":          session.Path,
+		"session_last_attached": lastAttached,
+		"session_created":       strconv.FormatInt(session.Created, 10),
+		"session_attached":      strconv.Itoa(attached),
+		"session_windows":       strconv.Itoa(len(session.Windows)),
+	}
+}
+
+// paneVars returns the format variables of pane, including those of its window and session.
+func (s *Server) paneVars(session *Session, window *Window, pane *Pane) map[string]string {
+	vars := s.sessionVars(session)
+	maps.Copy(vars, map[string]string{
+		"window_id":            "@" + strconv.Itoa(window.ID),
+		"window_index":         strconv.Itoa(window.Index),
+		"window_name":          window.Name,
+		"window_layout":        window.Layout,
+		"window_active":        boolVar(window.Active),
+		"pane_id":              "%" + strconv.Itoa(pane.ID),
+		"pane_index":           strconv.Itoa(pane.Index),
+		"pane_current_path":    pane.Path,
+		"pane_current_command": pane.Command,
+		"pane_active":          boolVar(pane.Active),
+		"pane_pid":             "0",
+	})
+	return vars
+}
+
+// clientVars returns the format variables of client.
+func (s *Server) clientVars(client *Client) map[string]string {
+	return map[string]string{
+		"client_name":         client.Name,
+		"client_tty":          client.TTY,
+		"client_session":      client.Session,
+		"client_last_session": client.LastSession,
+		"client_control_mode": boolVar(client.Control),
+	}
+}
+
+// boolVar formats a flag variable as tmux does.
+func boolVar(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// expand expands the variables in format, or in defaultFormat if format is empty. It understands
+// plain variables, #{?variable,then,else} conditionals and the ## escape. Unknown variables expand
+// to nothing.
+func (s *Server) expand(format, defaultFormat string, vars map[string]string) string {
+	if format == "" {
+		format = defaultFormat
+	}
+	var out strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '#' || i+1 >= len(format) {
+			out.WriteByte(format[i])
+			continue
+		}
+		switch format[i+1] {
+		case '#':
+			out.WriteByte('#')
+			i++
+		case '{':
+			end := matchingBrace(format, i+1)
+			if end < 0 {
+				out.WriteString(format[i:])
+				return out.String()
+			}
+			out.WriteString(s.expandVariable(format[i+2:end], vars))
+			i = end
+		default:
+			out.WriteByte('#')
+		}
+	}
+	return out.String()
+}
+
+// expandVariable expands the inside of a #{...} expression.
+func (s *Server) expandVariable(expr string, vars map[string]string) string {
+	condition, branches, ok := strings.Cut(expr, ",")
+	if !ok || !strings.HasPrefix(condition, "?") {
+		return vars[expr]
+	}
+	then, otherwise := splitBranches(branches)
+	if value := vars[condition[1:]]; value != "" && value != "0" {
+		return s.expand(then, "", vars)
+	}
+	return s.expand(otherwise, "", vars)
+}
+
+// splitBranches splits the branches of a conditional at the first comma outside of braces.
+func splitBranches(branches string) (then, otherwise string) {
+	depth := 0
+	for i := 0; i < len(branches); i++ {
+		switch branches[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				return branches[:i], branches[i+1:]
+			}
+		}
+	}
+	return branches, ""
+}
+
+// matchingBrace returns the index of the brace closing the one at open, or -1.
+func matchingBrace(format string, open int) int {
+	depth := 0
+	for i := open; i < len(format); i++ {
+		switch format[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// session returns the session named exactly name, or nil.
+func (s *Server) session(name string) *Session {
+	i := slices.IndexFunc(s.sessions, func(session *Session) bool { return session.Name == name })
+	if i < 0 {
+		return nil
+	}
+	return s.sessions[i]
+}
+
+// client returns the client with the given name or tty, or the current client if name is empty.
+func (s *Server) client(name string) (*Client, error) {
+	if name == "" {
+		name = s.Current
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no current client")
+	}
+	for _, client := range s.clients {
+		if client.Name == name || client.TTY == name {
+			return client, nil
+		}
+	}
+	return nil, fmt.Errorf("can't find client: %s", name)
+}
+
+// resolve resolves a target such as "=work:1.0", "work", "@3" or "%5" to a session, window and
+// pane. An empty target is the current client's session. A session name without a leading '=' may
+// be a unique prefix, and a window is found by index or by name.
+func (s *Server) resolve(target string) (*Session, *Window, *Pane, error) {
+	if id, ok := strings.CutPrefix(target, "%"); ok {
+		return s.resolveID(target, func(_ *Window, pane *Pane) bool { return strconv.Itoa(pane.ID) == id })
+	}
+	if id, ok := strings.CutPrefix(target, "@"); ok {
+		return s.resolveID(target, func(window *Window, pane *Pane) bool { return strconv.Itoa(window.ID) == id && pane.Active })
+	}
+
+	sessionPart, windowPart, _ := strings.Cut(target, ":")
+	windowPart, panePart, _ := strings.Cut(windowPart, ".")
+	var session *Session
+	switch {
+	case sessionPart == "":
+		client, err := s.client("")
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("can't find session: %s", target)
+		}
+		session = s.session(client.Session)
+	case strings.HasPrefix(sessionPart, "="):
+		session = s.session(sessionPart[1:])
+	default:
+		if session = s.session(sessionPart); session == nil {
+			var matches []*Session
+			for _, candidate := range s.sessions {
+				if strings.HasPrefix(candidate.Name, sessionPart) {
+					matches = append(matches, candidate)
+				}
+			}
+			if len(matches) == 1 {
+				session = matches[0]
+			}
+		}
+	}
+	if session == nil {
+		return nil, nil, nil, fmt.Errorf("can't find session: %s", strings.TrimPrefix(sessionPart, "="))
+	}
+
+	window := activeWindow(session)
+	if windowPart != "" {
+		i := slices.IndexFunc(session.Windows, func(w *Window) bool {
+			return strconv.Itoa(w.Index) == windowPart || w.Name == windowPart
+		})
+		if i < 0 {
+			return nil, nil, nil, fmt.Errorf("can't find window: %s", windowPart)
+		}
+		window = session.Windows[i]
+	}
+	pane := activePane(window)
+	if panePart != "" {
+		i := slices.IndexFunc(window.Panes, func(p *Pane) bool { return strconv.Itoa(p.Index) == panePart })
+		if i < 0 {
+			return nil, nil, nil, fmt.Errorf("can't find pane: %s", panePart)
+		}
+		pane = window.Panes[i]
+	}
+	return session, window, pane, nil
+}
+
+// resolveID finds the pane for which match returns true.
+func (s *Server) resolveID(target string, match func(*Window, *Pane) bool) (*Session, *Window, *Pane, error) {
+	for _, session := range s.sessions {
+		for _, window := range session.Windows {
+			for _, pane := range window.Panes {
+				if match(window, pane) {
+					return session, window, pane, nil
+				}
+			}
+		}
+	}
+	return nil, nil, nil, fmt.Errorf("can't find target: %s", target)
+}
+
+// activeWindow returns the active window of session.
+func activeWindow(session *Session) *Window {
+	for _, window := range session.Windows {
+		if window.Active {
+			return window
+		}
+	}
+	return session.Windows[0]
+}
+
+// activePane returns the active pane of window.
+func activePane(window *Window) *Pane {
+	for _, pane := range window.Panes {
+		if pane.Active {
+			return pane
+		}
+	}
+	return window.Panes[0]
+}
@@ -0,0 +1,290 @@
+// Package tmuxfake is an in-memory model of a tmux server.
+//
+// It understands the subset of tmux commands that treemux issues, answering queries from its model
+// and applying changes to it, and records every command. Tests use it in place of a tmux server,
+// and `treemux --dry-run` uses it, seeded from the real server, to print the changes treemux would
+// have made.
+package tmuxfake
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ian-howell/treemux/internal/tmux"
+)
+
+// Server is an in-memory tmux server. The zero value is an empty server.
+type Server struct {
+	// Out, if set, receives each command that changes the server, one per line.
+	Out io.Writer
+
+	// Current names the client that commands without an explicit client act on, like the client a
+	// command run inside tmux belongs to. It is matched against client names and ttys.
+	Current string
+
+	// Version is the version `tmux -V` reports, e.g. "3.3a". Empty reports a development build,
+	// which supports every feature.
+	Version string
+
+	// Stopped makes every command but -V fail as tmux does when no server is listening.
+	Stopped bool
+
+	mu       sync.Mutex
+	sessions []*Session
+	clients  []*Client
+	calls    [][]string
+
+	// clock orders attach times. It only ever increases.
+	clock int64
+
+	// nextID is the last ID given to a window or pane. IDs are unique across the server.
+	nextID int
+}
+
+// Session is a session of the model.
+type Session struct {
+	Name         string
+	Path         string
+	LastAttached int64
+	Created      int64
+	Windows      []*Window
+}
+
+// Window is a window of the model.
+type Window struct {
+	ID     int
+	Index  int
+	Name   string
+	Layout string
+	Active bool
+	Panes  []*Pane
+}
+
+// Pane is a pane of the model.
+type Pane struct {
+	ID      int
+	Index   int
+	Path    string
+	Command string
+	Active  bool
+
+	// Content holds the lines typed into the pane, which capture-pane returns.
+	Content []string
+}
+
+// Client is a client attached to the model.
+type Client struct {
+	Name        string
+	TTY         string
+	Session     string
+	LastSession string
+	Control     bool
+}
+
+// AddSession adds a session rooted at path with the named windows, or a single window named "sh"
+// if none are given.
+func (s *Server) AddSession(name, path string, windows ...string) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session := &Session{Name: name, Path: path}
+	s.sessions = append(s.sessions, session)
+	if len(windows) == 0 {
+		windows = []string{"sh"}
+	}
+	for i, window := range windows {
+		s.addWindow(session, i, window, path).Active = i == 0
+	}
+	return session
+}
+
+// AddClient attaches a client to session and makes it the most recently attached client there.
+func (s *Server) AddClient(client Client) *Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := &client
+	s.clients = append(s.clients, c)
+	if session := s.session(client.Session); session != nil {
+		s.clock++
+		session.LastAttached = s.clock
+	}
+	return c
+}
+
+// Sessions returns the sessions, in creation order.
+func (s *Server) Sessions() []*Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.sessions)
+}
+
+// Clients returns the attached clients.
+func (s *Server) Clients() []*Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.clients)
+}
+
+// Calls returns every command run so far, including queries.
+func (s *Server) Calls() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.calls)
+}
+
+// Run runs a tmux command against the model. It implements tmux.Runner. Errors are reported both
+// on stderr and as the error, as tmux reports them through its exit status.
+func (s *Server) Run(args []string) (stdout, stderr string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	args = stripGlobalFlags(args)
+	s.calls = append(s.calls, args)
+	if len(args) == 0 {
+		return "", "", nil
+	}
+	if s.Stopped && args[0] != "-V" {
+		err = errors.New("no server running on /tmp/tmux-fake/default")
+		return "", err.Error(), err
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		err = fmt.Errorf("unknown command: %s", args[0])
+		return "", err.Error(), err
+	}
+	if command.mutates && s.Out != nil {
+		fmt.Fprintln(s.Out, commandLine(args))
+	}
+	stdout, err = command.run(s, parseArgs(args[1:], command.valueFlags))
+	if err != nil {
+		return "", err.Error(), err
+	}
+	return stdout, "", nil
+}
+
+// Exec runs a tmux command against the model in place of replacing the process. It implements
+// tmux.Runner.
+func (s *Server) Exec(args []string) error {
+	_, _, err := s.Run(args)
+	return err
+}
+
+// RunCmd runs a tmux command against the model and returns its output, like tmux.Client.RunCmd.
+func (s *Server) RunCmd(args []string) (string, error) {
+	stdout, _, err := s.Run(args)
+	return stdout, err
+}
+
+// AttachOrSwitch switches the current client to the named session or, without a current client,
+// attaches a new one, like tmux.Client.AttachOrSwitch.
+func (s *Server) AttachOrSwitch(name string) error {
+	if s.Current != "" {
+		return s.Exec([]string{"switch-client", "-t", "=" + name})
+	}
+	return s.Exec([]string{"attach-session", "-t", "=" + name})
+}
+
+// querier runs tmux commands on a real server.
+type querier interface {
+	RunCmd(args []string) (stdout string, err error)
+}
+
+// seedPaneFormat lists the fields Seed copies from each pane of a real server.
+const seedPaneFormat = "#{session_name}\t#{session_path}\t#{session_last_attached}\t#{window_id}\t#{window_index}\t" +
+	"#{window_name}\t#{window_layout}\t#{window_active}\t#{pane_id}\t#{pane_index}\t#{pane_current_path}\t" +
+	"#{pane_current_command}\t#{pane_active}"
+
+// Seed copies the version, sessions, windows, panes and clients of the real server that tmux talks
+// to. A server that is not running leaves the model empty.
+func (s *Server) Seed(tmux querier) {
+	if output, err := tmux.RunCmd([]string{"-V"}); err == nil {
+		s.Version = strings.TrimPrefix(strings.TrimSpace(output), "tmux ")
+	}
+	output, err := tmux.RunCmd([]string{"list-panes", "-a", "-F", seedPaneFormat})
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 13 {
+			continue
+		}
+		session := s.session(fields[0])
+		if session == nil {
+			lastAttached, _ := strconv.ParseInt(fields[2], 10, 64)
+			session = &Session{Name: fields[0], Path: fields[1], LastAttached: lastAttached}
+			s.sessions = append(s.sessions, session)
+			s.clock = max(s.clock, lastAttached)
+		}
+		windowID := parseID(fields[3])
+		i := slices.IndexFunc(session.Windows, func(w *Window) bool { return w.ID == windowID })
+		if i < 0 {
+			index, _ := strconv.Atoi(fields[4])
+			session.Windows = append(session.Windows, &Window{
+				ID:     windowID,
+				Index:  index,
+				Name:   fields[5],
+				Layout: fields[6],
+				Active: fields[7] == "1",
+			})
+			i = len(session.Windows) - 1
+		}
+		window := session.Windows[i]
+		paneIndex, _ := strconv.Atoi(fields[9])
+		pane := &Pane{
+			ID:      parseID(fields[8]),
+			Index:   paneIndex,
+			Path:    fields[10],
+			Command: fields[11],
+			Active:  fields[12] == "1",
+		}
+		window.Panes = append(window.Panes, pane)
+		s.nextID = max(s.nextID, windowID, pane.ID)
+	}
+
+	output, err = tmux.RunCmd([]string{"list-clients", "-F", "#{client_name}\t#{client_tty}\t#{client_session}\t#{client_last_session}\t#{client_control_mode}"})
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			continue
+		}
+		s.clients = append(s.clients, &Client{
+			Name:        fields[0],
+			TTY:         fields[1],
+			Session:     fields[2],
+			LastSession: fields[3],
+			Control:     fields[4] == "1",
+		})
+	}
+}
+
+// parseID parses a window or pane ID such as "@3" or "%5".
+func parseID(id string) int {
+	n, _ := strconv.Atoi(strings.TrimLeft(id, "@%$"))
+	return n
+}
+
+// stripGlobalFlags removes the flags that select a server, such as -S, which the model ignores.
+func stripGlobalFlags(args []string) []string {
+	for len(args) > 1 && (args[0] == "-S" || args[0] == "-L" || args[0] == "-f") {
+		args = args[2:]
+	}
+	return args
+}
+
+// commandLine formats args as a tmux command line.
+func commandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = tmux.Quote(arg)
+	}
+	return "tmux " + strings.Join(quoted, " ")
+}
@@ -0,0 +1,97 @@
+package tmuxfake
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ian-howell/treemux/internal/snapshot"
+	"github.com/ian-howell/treemux/internal/tmux"
+)
+
+func TestClientCommands(t *testing.T) {
+	server := &Server{}
+	server.AddSession("work", "/work", "edit", "logs")
+	server.AddClient(Client{Name: "client-1", TTY: "/dev/pts/1", Session: "work"})
+	var out strings.Builder
+	server.Out = &out
+	client := tmux.New(tmux.WithRunner(server), tmux.WithClient("/dev/pts/1"))
+
+	tests := []struct {
+		name string
+		run  func() (any, error)
+		want any
+	}{
+		{"has exact session", func() (any, error) { return client.HasSession("work"), nil }, true},
+		{"no prefix match for exact names", func() (any, error) { return client.HasSession("wor"), nil }, false},
+		{"current session", func() (any, error) { return client.CurrentSession(), nil }, "work"},
+		{"create session", func() (any, error) { return nil, client.NewSession("notes", "/notes") }, nil},
+		{"create window", func() (any, error) { return nil, client.NewWindow("notes", "todo", "/notes/todo") }, nil},
+		{"type into window", func() (any, error) { return nil, client.SendKeys("notes", "todo", "vim todo.md") }, nil},
+		{"capture typed keys", func() (any, error) { return client.CapturePane("=notes:todo") }, "vim todo.md\n"},
+		{"type unspaced metacharacters", func() (any, error) { return nil, client.SendKeys("notes", "todo", "ls|wc") }, nil},
+		{"switch", func() (any, error) { return nil, client.AttachOrSwitch("notes") }, nil},
+		{"session after switch", func() (any, error) { return client.CurrentSession(), nil }, "notes"},
+		{"last session", func() (any, error) { return client.LastSession(), nil }, "work"},
+	}
+	for _, test := range tests {
+		got, err := test.run()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got != test.want {
+			t.Fatalf("%s: expected %v, got %v", test.name, test.want, got)
+		}
+	}
+
+	if err := client.AttachOrSwitch("missing"); err == nil {
+		t.Fatal("expected switching to a missing session to fail")
+	}
+	want := strings.Join([]string{
+		"tmux new-session -d -s notes -c /notes",
+		"tmux new-window -d -t =notes: -n todo -c /notes/todo",
+		"tmux send-keys -t =notes:todo 'vim todo.md' Enter",
+		"tmux send-keys -t =notes:todo 'ls|wc' Enter",
+		"tmux switch-client -c /dev/pts/1 -t =notes",
+		"tmux switch-client -c /dev/pts/1 -t =missing",
+	}, "\n") + "\n"
+	if out.String() != want {
+		t.Fatalf("expected changes\n%s\ngot\n%s", want, out.String())
+	}
+	if calls := server.Calls(); !slices.Equal(calls[0], []string{"has-session", "-t", "=work"}) {
+		t.Fatalf("expected every call to be recorded, got %q first", calls[0])
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	server := &Server{}
+	server.AddSession("work", "/work", "edit", "logs")
+	if _, err := server.RunCmd([]string{"split-window", "-d", "-t", "=work:1", "-c", "/work/logs"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.RunCmd([]string{"send-keys", "-t", "=work:1.1", "tail -f log", "Enter"}); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := snapshot.Capture(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.RunCmd([]string{"kill-session", "-t", "=work"}); err != nil {
+		t.Fatal(err)
+	}
+	session, _ := saved.Session("work")
+	if err := snapshot.Restore(server, session, snapshot.RestoreOptions{Commands: true}); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := snapshot.Capture(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := restored.Session("work")
+	if len(got.Windows) != 2 || len(got.Windows[1].Panes) != 2 {
+		t.Fatalf("expected two windows with two panes in the second, got %+v", got)
+	}
+	if pane := got.Windows[1].Panes[1]; pane.Path != "/work/logs" || pane.Command != "tail" {
+		t.Fatalf("expected tail to run again in /work/logs, got %+v", pane)
+	}
+}
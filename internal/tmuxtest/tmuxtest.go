@@ -0,0 +1,120 @@
+// Package tmuxtest runs tests against a private tmux server, leaving the developer's own server
+// alone.
+//
+// Each Server listens on a socket in its own temporary directory, is started with a minimal
+// configuration, and is killed when the test ends.
+package tmuxtest
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ian-howell/treemux/internal/tmux"
+)
+
+// config is the configuration the server starts with, in place of the user's tmux.conf. The
+// server is kept alive without sessions so that tests can start from an empty server.
+const config = `set -s exit-empty off
+set -g default-shell /bin/sh
+set -g base-index 0
+set -g history-limit 100
+`
+
+// Server is a private tmux server for a single test.
+type Server struct {
+	// Socket is the path of the server's socket.
+	Socket string
+
+	t testing.TB
+}
+
+// NewServer starts a private tmux server, skipping the test if tmux is not installed. The server is
+// killed, and its directory removed, when the test ends.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux is not installed")
+	}
+	// Unix socket paths are limited in length, so avoid the long per-test temporary directory.
+	dir, err := os.MkdirTemp("", "treemux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	conf := filepath.Join(dir, "tmux.conf")
+	if err := os.WriteFile(conf, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Code under test must not find its way to the server treemux may be running in.
+	t.Setenv("TMUX", "")
+	t.Setenv("TMUX_PANE", "")
+
+	s := &Server{Socket: filepath.Join(dir, "sock"), t: t}
+	s.Run("-f", conf, "start-server")
+	t.Cleanup(func() { _ = exec.Command("tmux", "-S", s.Socket, "kill-server").Run() })
+	return s
+}
+
+// Client returns a tmux client bound to the server, configured by options.
+func (s *Server) Client(options ...tmux.Option) *tmux.Client {
+	return tmux.New(append([]tmux.Option{tmux.WithSocket(s.Socket)}, options...)...)
+}
+
+// Run runs a tmux command on the server and returns its output without the trailing newline,
+// failing the test on error.
+func (s *Server) Run(args ...string) string {
+	s.t.Helper()
+	output, err := exec.Command("tmux", append([]string{"-S", s.Socket}, args...)...).CombinedOutput()
+	if err != nil {
+		s.t.Fatalf("tmux %s: %v: %s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSuffix(string(output), "\n")
+}
+
+// NewSession seeds a detached session rooted at dir.
+func (s *Server) NewSession(name, dir string) {
+	s.t.Helper()
+	s.Run("new-session", "-d", "-s", name, "-c", dir)
+}
+
+// NewWindow seeds a window in session rooted at dir.
+func (s *Server) NewWindow(session, name, dir string) {
+	s.t.Helper()
+	s.Run("new-window", "-d", "-t", "="+session+":", "-n", name, "-c", dir)
+}
+
+// Sessions returns the names of the server's sessions.
+func (s *Server) Sessions() []string {
+	s.t.Helper()
+	output := s.Run("list-sessions", "-F", "#{session_name}")
+	if output == "" {
+		return nil
+	}
+	return strings.Split(output, "\n")
+}
+
+// AttachClient attaches a client to session and returns its name, which tmux accepts wherever it
+// expects a client. The client is a control-mode client, since a regular one needs a terminal.
+// It stays attached until the test ends.
+func (s *Server) AttachClient(session string) string {
+	s.t.Helper()
+	control, err := tmux.NewControl(tmux.WithControlSocket(s.Socket), tmux.WithControlSession(session))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.t.Cleanup(func() { control.Close() })
+	name, err := control.RunCmd([]string{"display-message", "-p", "#{client_name}"})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return strings.TrimSpace(name)
+}
+
+// ClientSession returns the session the named client is attached to.
+func (s *Server) ClientSession(client string) string {
+	s.t.Helper()
+	return s.Run("display-message", "-p", "-c", client, "#{session_name}")
+}
@@ -2,22 +2,70 @@
 package treemux
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ian-howell/treemux/internal/executor"
 )
 
 type Session interface {
 	Attach() error
 	fmt.Stringer
+
+	// Name returns the tmux session name.
+	Name() string
+
+	// Path returns the session's working directory, or "" if it has none.
+	Path() string
+
+	// Group returns the names of the tree nodes the session is nested under, outermost first,
+	// e.g. its repository and worktree. An empty group places the session at the top level.
+	Group() []string
+}
+
+// Nested is implemented by sessions that stand for part of a tmux session, such as one of its
+// windows. Owner returns the name of the tmux session they belong to.
+type Nested interface {
+	Owner() string
+}
+
+// owner returns the name of the tmux session that session belongs to.
+func owner(session Session) string {
+	if nested, ok := session.(Nested); ok {
+		return nested.Owner()
+	}
+	return session.Name()
 }
 
 type Lister interface {
 	List() ([]Session, error)
 }
 
+// Counter is implemented by listers that run their commands with an executor of their own. Stats
+// returns the commands they have run so far, which the debug log reports for each lister.
+type Counter interface {
+	Stats() executor.Stats
+}
+
+// Watcher is implemented by listers whose results can change after List returns. Each value
+// received from Updates replaces everything that lister previously returned. The channel is
+// closed when no more updates will come; a nil channel means there are none.
+type Watcher interface {
+	Updates() <-chan []Session
+}
+
 // The prompter is needed to provide a UI for the user to select a Session.
+// The initial index is a hint for which session the cursor should start on.
+// Updates, when not nil, delivers complete replacement session lists while the prompt is open, and
+// is closed when no more will come.
 // Callers should assume that a returned nil Session implies that the user canceled the prompt
 type prompter interface {
-	Prompt(sessions []Session) (Session, error)
+	Prompt(sessions []Session, initial int, updates <-chan []Session) (Session, error)
 }
 
 // App bundles core treemux dependencies.
@@ -27,6 +75,24 @@ type App struct {
 
 	// prompter provides session selection UI.
 	prompter prompter
+
+	// workingDir is the directory used to preselect a session. It defaults to the current
+	// directory.
+	workingDir string
+
+	// lastSession returns the name of the previously attached session, if known.
+	lastSession func() string
+
+	// history records every session attached through the app.
+	history historyRecorder
+
+	// printer, if set, writes the selected session instead of attaching to it.
+	printer *Printer
+}
+
+// historyRecorder records attached sessions so they can be revisited later.
+type historyRecorder interface {
+	Record(session string) error
 }
 
 type Option func(*App)
@@ -43,6 +109,36 @@ func WithPrompter(prompter prompter) Option {
 	}
 }
 
+// WithWorkingDir sets the directory used to preselect the session containing it.
+func WithWorkingDir(dir string) Option {
+	return func(app *App) {
+		app.workingDir = dir
+	}
+}
+
+// WithLastSession sets how the previously attached session is found. It is preselected when no
+// session contains the working directory.
+func WithLastSession(lastSession func() string) Option {
+	return func(app *App) {
+		app.lastSession = lastSession
+	}
+}
+
+// WithHistory records every session attached through the app.
+func WithHistory(history historyRecorder) Option {
+	return func(app *App) {
+		app.history = history
+	}
+}
+
+// WithPrinter writes the selected session with the printer instead of attaching to it. Nothing is
+// recorded in the history, since nothing is attached.
+func WithPrinter(printer *Printer) Option {
+	return func(app *App) {
+		app.printer = printer
+	}
+}
+
 // New returns a new App instance.
 func New(opts ...Option) (*App, error) {
 	app := &App{}
@@ -62,21 +158,41 @@ func New(opts ...Option) (*App, error) {
 	return app, nil
 }
 
+// Run lists the sessions, prompts for one and attaches to it, or prints it. It returns ErrCanceled
+// if the prompt is dismissed.
 func (a *App) Run() error {
-	sessions, err := a.listSessions()
+	results, err := a.listSessions()
 	if err != nil {
 		return fmt.Errorf("failed to list sessions: %w", err)
 	}
+	sessions := flatten(results)
 
-	session, err := a.prompter.Prompt(sessions)
+	done := make(chan struct{})
+	updates := a.watch(results, done)
+	session, err := a.prompter.Prompt(sessions, a.initialSelection(sessions), updates)
+	close(done)
 	if err != nil {
 		return fmt.Errorf("failed to prompt for session: %w", err)
 	}
 	if session == nil {
 		// Assume that the user cancelled the selection
+		return ErrCanceled
+	}
+
+	if a.printer != nil {
+		if err := a.printer.Print(session); err != nil {
+			return fmt.Errorf("failed to print session: %w", err)
+		}
 		return nil
 	}
 
+	if a.history != nil {
+		// History is best effort. It is recorded before attaching because attaching may replace
+		// the process, and failing to record must never prevent the attach.
+		// Jump lists hold sessions, so a window is recorded as its session.
+		_ = a.history.Record(owner(session))
+	}
+
 	if err := session.Attach(); err != nil {
 		return fmt.Errorf("failed to attach to session: %w", err)
 	}
@@ -84,15 +200,106 @@ func (a *App) Run() error {
 	return nil
 }
 
-func (a *App) listSessions() ([]Session, error) {
+// initialSelection returns the index of the session the prompter should start on: the session
+// whose path most closely contains the working directory, or else the previously attached session.
+func (a *App) initialSelection(sessions []Session) int {
+	dir := a.workingDir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	best, bestLen := -1, 0
+	for i, session := range sessions {
+		path := session.Path()
+		if path == "" || !containsPath(path, dir) {
+			continue
+		}
+		if len(path) > bestLen {
+			best, bestLen = i, len(path)
+		}
+	}
+	if best >= 0 {
+		return best
+	}
+	if a.lastSession != nil {
+		if name := a.lastSession(); name != "" {
+			for i, session := range sessions {
+				if session.Name() == name {
+					return i
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// containsPath reports whether dir is root or a directory beneath it.
+func containsPath(root, dir string) bool {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// listSessions returns the sessions of each lister, in lister order. With debug logging enabled,
+// it logs how long each lister took and, for each Counter, the commands it ran. Other listers share
+// the default executor with everything running in the background, so their commands cannot be
+// told apart.
+func (a *App) listSessions() ([][]Session, error) {
 	// TODO: Handle duplicates and sorting
-	var allSessions []Session
+	results := make([][]Session, 0, len(a.listers))
+	// Without a server other listers, e.g. of saved sessions, may still have sessions to offer. If
+	// none do, there are no sessions, which also tells that tmux is not running.
+	var notRunning error
 	for _, lister := range a.listers {
+		counter, counted := lister.(Counter)
+		var before executor.Stats
+		if counted {
+			before = counter.Stats()
+		}
+		start := time.Now()
 		sessions, err := lister.List()
+		attrs := []any{
+			slog.String("lister", ListerName(lister)),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int("sessions", len(sessions)),
+		}
+		if counted {
+			after := counter.Stats()
+			attrs = append(attrs,
+				slog.Int("commands", after.Commands-before.Commands),
+				slog.Duration("command_time", after.Duration-before.Duration),
+			)
+		}
+		slog.Debug("lister", attrs...)
+		if errors.Is(err, ErrTmuxNotRunning) {
+			notRunning = err
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
+		results = append(results, sessions)
+	}
+	if notRunning != nil && len(flatten(results)) == 0 {
+		return nil, fmt.Errorf("%w: %w", ErrNoSessions, notRunning)
+	}
+	return results, nil
+}
+
+// ListerName describes a lister for logs: its String method if it has one, else its type.
+func ListerName(lister Lister) string {
+	if stringer, ok := lister.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", lister), "*")
+}
+
+// flatten concatenates the sessions of each lister.
+func flatten(results [][]Session) []Session {
+	var allSessions []Session
+	for _, sessions := range results {
 		allSessions = append(allSessions, sessions...)
 	}
-	return allSessions, nil
+	return allSessions
 }
@@ -0,0 +1,144 @@
+package treemux
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// fakeSession is a Session with a fixed name, path and group.
+type fakeSession struct {
+	name  string
+	path  string
+	group []string
+}
+
+func (s fakeSession) Attach() error   { return nil }
+func (s fakeSession) String() string  { return s.name }
+func (s fakeSession) Name() string    { return s.name }
+func (s fakeSession) Path() string    { return s.path }
+func (s fakeSession) Group() []string { return s.group }
+
+// staticLister lists fixed sessions.
+type staticLister []Session
+
+func (l staticLister) List() ([]Session, error) { return l, nil }
+
+// failingLister fails with a fixed error.
+type failingLister struct{ err error }
+
+func (l failingLister) List() ([]Session, error) { return nil, l.err }
+
+// pickPrompter selects the session with a fixed name.
+type pickPrompter string
+
+func (p pickPrompter) Prompt(sessions []Session, _ int, _ <-chan []Session) (Session, error) {
+	for _, session := range sessions {
+		if session.Name() == string(p) {
+			return session, nil
+		}
+	}
+	return nil, nil
+}
+
+// fakeWindow is a window of a fakeSession.
+type fakeWindow struct {
+	fakeSession
+	owner string
+}
+
+func (w fakeWindow) Owner() string { return w.owner }
+
+// recordingHistory collects the recorded sessions.
+type recordingHistory struct {
+	sessions []string
+}
+
+func (h *recordingHistory) Record(session string) error {
+	h.sessions = append(h.sessions, session)
+	return nil
+}
+
+func TestRunRecordsSessions(t *testing.T) {
+	sessions := staticLister{
+		fakeSession{name: "work"},
+		fakeWindow{fakeSession: fakeSession{name: "work:1"}, owner: "work"},
+	}
+	history := &recordingHistory{}
+	for _, name := range []string{"work", "work:1"} {
+		app, err := New(WithListers([]Lister{sessions}), WithPrompter(pickPrompter(name)), WithHistory(history))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := app.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A window is recorded as its session, which the jump list and its hooks know.
+	if want := []string{"work", "work"}; !slices.Equal(history.sessions, want) {
+		t.Fatalf("expected %v, got %v", want, history.sessions)
+	}
+}
+
+func TestInitialSelection(t *testing.T) {
+	sessions := []Session{
+		fakeSession{name: "home", path: "/home/me"},
+		fakeSession{name: "repo", path: "/home/me/repo"},
+		fakeSession{name: "feature", path: "/home/me/repo/.worktrees/feature"},
+		fakeSession{name: "scratch"},
+	}
+	tests := []struct {
+		name        string
+		workingDir  string
+		lastSession string
+		want        int
+	}{
+		{name: "deepest containing session", workingDir: "/home/me/repo/.worktrees/feature/src", want: 2},
+		{name: "exact path", workingDir: "/home/me/repo", want: 1},
+		{name: "sibling prefix does not match", workingDir: "/home/mel", lastSession: "scratch", want: 3},
+		{name: "falls back to last session", workingDir: "/tmp", lastSession: "scratch", want: 3},
+		{name: "unknown last session", workingDir: "/tmp", lastSession: "gone", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{
+				workingDir:  tt.workingDir,
+				lastSession: func() string { return tt.lastSession },
+			}
+			if got := app.initialSelection(sessions); got != tt.want {
+				t.Fatalf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRunWithoutServer(t *testing.T) {
+	notRunning := failingLister{fmt.Errorf("%w: no server running", ErrTmuxNotRunning)}
+	broken := errors.New("broken")
+	tests := []struct {
+		name     string
+		listers  []Lister
+		wantErrs []error
+	}{
+		// Scripts waiting for "no sessions" get it, and can still tell that tmux is not running.
+		{name: "no other sessions", listers: []Lister{notRunning, staticLister{}}, wantErrs: []error{ErrNoSessions, ErrTmuxNotRunning}},
+		// Saved sessions, say, can still be picked.
+		{name: "other sessions", listers: []Lister{notRunning, staticLister{fakeSession{name: "saved"}}}, wantErrs: []error{nil}},
+		{name: "other failures", listers: []Lister{failingLister{broken}, staticLister{fakeSession{name: "saved"}}}, wantErrs: []error{broken}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app, err := New(WithListers(test.listers), WithPrompter(pickPrompter("saved")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = app.Run()
+			for _, want := range test.wantErrs {
+				if !errors.Is(err, want) {
+					t.Fatalf("expected %v, got %v", want, err)
+				}
+			}
+		})
+	}
+}
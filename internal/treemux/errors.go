@@ -0,0 +1,42 @@
+package treemux
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ian-howell/treemux/internal/git"
+	"github.com/ian-howell/treemux/internal/tmux"
+)
+
+// The errors that scripts can tell apart. The CLI exits with a distinct status for each; match
+// them with errors.Is.
+var (
+	// ErrNoSessions is returned when there are no sessions to choose from.
+	ErrNoSessions = errors.New("no sessions available")
+
+	// ErrCanceled is returned when the prompt is dismissed without choosing a session.
+	ErrCanceled = errors.New("selection canceled")
+
+	// ErrTmuxNotRunning is returned when no tmux server is listening on the socket.
+	ErrTmuxNotRunning = tmux.ErrNoServer
+
+	// ErrNotRepository is returned when a command needs a git repository and the directory is not
+	// inside one.
+	ErrNotRepository = git.ErrNotRepository
+
+	// ErrSessionNotFound is matched by every *SessionNotFoundError.
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// SessionNotFoundError reports that no session has the requested name.
+type SessionNotFoundError struct {
+	Name string
+}
+
+func (e *SessionNotFoundError) Error() string {
+	return fmt.Sprintf("no session named %q", e.Name)
+}
+
+func (e *SessionNotFoundError) Unwrap() error {
+	return ErrSessionNotFound
+}
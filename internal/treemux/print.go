@@ -0,0 +1,79 @@
+package treemux
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// Selection is the selected session as a Printer sees it.
+type Selection struct {
+	Name  string   `json:"name"`
+	Path  string   `json:"path"`
+	Group []string `json:"group"`
+
+	// Label is the session as the prompter displayed it.
+	Label string `json:"label"`
+}
+
+// Printer writes the selected session instead of attaching to it, for shell pipelines and editor
+// integrations, e.g. `cd "$(treemux --print-format path)"`.
+type Printer struct {
+	w io.Writer
+
+	// write formats the selection onto w.
+	write func(w io.Writer, selection Selection) error
+}
+
+// NewPrinter returns a printer writing to w in format: "name" or "" for the session name, "path"
+// for its directory, "json" for a Selection object, or a text/template over Selection such as
+// "{{.Name}} {{.Path}}". Each selection is followed by a newline.
+func NewPrinter(w io.Writer, format string) (*Printer, error) {
+	p := &Printer{w: w}
+	switch format {
+	case "", "name":
+		p.write = func(w io.Writer, s Selection) error {
+			_, err := fmt.Fprintln(w, s.Name)
+			return err
+		}
+	case "path":
+		p.write = func(w io.Writer, s Selection) error {
+			_, err := fmt.Fprintln(w, s.Path)
+			return err
+		}
+	case "json":
+		p.write = func(w io.Writer, s Selection) error {
+			return json.NewEncoder(w).Encode(s)
+		}
+	default:
+		tmpl, err := template.New("print").Option("missingkey=error").Parse(format)
+		if err != nil {
+			return nil, fmt.Errorf("invalid print format: %w", err)
+		}
+		p.write = func(w io.Writer, s Selection) error {
+			var out strings.Builder
+			if err := tmpl.Execute(&out, s); err != nil {
+				return fmt.Errorf("invalid print format: %w", err)
+			}
+			_, err := fmt.Fprintln(w, strings.TrimSuffix(out.String(), "\n"))
+			return err
+		}
+	}
+	return p, nil
+}
+
+// Print writes the session.
+func (p *Printer) Print(session Session) error {
+	group := session.Group()
+	if group == nil {
+		group = []string{}
+	}
+	return p.write(p.w, Selection{
+		Name:  session.Name(),
+		Path:  session.Path(),
+		Group: group,
+		Label: strings.TrimSpace(session.String()),
+	})
+}
@@ -0,0 +1,68 @@
+package treemux
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// unattachableSession fails the test if it is attached.
+type unattachableSession struct {
+	fakeSession
+}
+
+func (unattachableSession) Attach() error { return errors.New("attached") }
+
+func TestPrint(t *testing.T) {
+	session := unattachableSession{fakeSession{name: "api", path: "/src/api", group: []string{"src", "api"}}}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "", want: "api\n"},
+		{format: "name", want: "api\n"},
+		{format: "path", want: "/src/api\n"},
+		{format: "json", want: `{"name":"api","path":"/src/api","group":["src","api"],"label":"api"}` + "\n"},
+		{format: "{{.Name}}={{.Path}}\n", want: "api=/src/api\n"},
+		{format: `{{join .Group "/"}}`, want: "invalid print format"},
+		{format: "{{.Missing}}", want: "invalid print format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out strings.Builder
+			printer, err := NewPrinter(&out, tt.format)
+			if err == nil {
+				var app *App
+				app, err = New(
+					WithListers([]Lister{staticLister{fakeSession{name: "other"}, session}}),
+					WithPrompter(pickPrompter("api")),
+					WithPrinter(printer),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = app.Run()
+			}
+			if err != nil {
+				if !strings.Contains(err.Error(), tt.want) {
+					t.Fatalf("expected %q, got %v", tt.want, err)
+				}
+				return
+			}
+			if out.String() != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, out.String())
+			}
+		})
+	}
+
+	// A canceled prompt prints nothing.
+	var out strings.Builder
+	printer, _ := NewPrinter(&out, "name")
+	app, err := New(WithListers([]Lister{staticLister{session}}), WithPrompter(pickPrompter("gone")), WithPrinter(printer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Run(); !errors.Is(err, ErrCanceled) || out.Len() != 0 {
+		t.Fatalf("expected no output, got %q, %v", out.String(), err)
+	}
+}
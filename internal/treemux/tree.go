@@ -0,0 +1,59 @@
+package treemux
+
+// Node is an entry in the session tree. Group nodes have children and no session; leaves carry a
+// session. A node can have both, e.g. a session whose windows are nested beneath it.
+type Node struct {
+	// Name is the label of the node within its parent.
+	Name string
+
+	// Session is the session selected by this node, or nil for pure group nodes.
+	Session Session
+
+	// Children are the nested nodes, in the order they were first seen.
+	Children []*Node
+}
+
+// BuildTree arranges sessions into a tree using their groups. Each session becomes the node named
+// after it beneath its group's nodes. Sibling order follows the order of sessions, so sorting done
+// by listers is preserved.
+func BuildTree(sessions []Session) *Node {
+	root := &Node{}
+	for _, session := range sessions {
+		node := root
+		for _, name := range session.Group() {
+			node = node.child(name)
+		}
+		node = node.child(session.Name())
+		if node.Session == nil {
+			node.Session = session
+		}
+	}
+	return root
+}
+
+// child returns the child with the given name, creating it if needed.
+func (n *Node) child(name string) *Node {
+	for _, child := range n.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	child := &Node{Name: name}
+	n.Children = append(n.Children, child)
+	return child
+}
+
+// Walk calls fn for the node and each of its descendants in depth-first order, passing each
+// node's depth below n. Children of a node are skipped when fn returns false for it.
+func (n *Node) Walk(fn func(node *Node, depth int) bool) {
+	n.walk(fn, 0)
+}
+
+func (n *Node) walk(fn func(node *Node, depth int) bool, depth int) {
+	if !fn(n, depth) {
+		return
+	}
+	for _, child := range n.Children {
+		child.walk(fn, depth+1)
+	}
+}
@@ -0,0 +1,113 @@
+package treemux
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ClosedSession is a session that disappeared from its lister while the prompter was open. It
+// stays listed where it was, so that the list does not shift under the cursor, but can no longer be
+// attached.
+type ClosedSession struct {
+	Session
+}
+
+// Attach fails, since the session no longer exists.
+func (s ClosedSession) Attach() error {
+	return fmt.Errorf("session %q was closed", s.Name())
+}
+
+// String marks the session as closed.
+func (s ClosedSession) String() string {
+	return s.Session.String() + " (closed)"
+}
+
+// watch merges the updates of every Watcher lister into a single stream of complete session
+// lists, in which sessions that disappeared are kept as ClosedSession. It returns nil if no lister
+// has updates. The stream is closed once every watcher is exhausted, and forwarding stops when
+// done is closed.
+func (a *App) watch(results [][]Session, done <-chan struct{}) <-chan []Session {
+	var (
+		// mu guards results and serializes sends so that lists arrive in the order they were
+		// merged.
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		watching int
+		combined = make(chan []Session)
+		shown    = flatten(results)
+	)
+	for i, lister := range a.listers {
+		watcher, ok := lister.(Watcher)
+		if !ok {
+			continue
+		}
+		updates := watcher.Updates()
+		if updates == nil {
+			continue
+		}
+		watching++
+		wg.Go(func() {
+			for sessions := range updates {
+				mu.Lock()
+				results[i] = sessions
+				shown = markClosed(shown, flatten(results))
+				select {
+				case combined <- shown:
+					mu.Unlock()
+				case <-done:
+					mu.Unlock()
+					return
+				}
+			}
+		})
+	}
+	if watching == 0 {
+		return nil
+	}
+	go func() {
+		wg.Wait()
+		close(combined)
+	}()
+	return combined
+}
+
+// markClosed returns next with every session of previous that is missing from it added back as a
+// ClosedSession, right after the session it followed before.
+func markClosed(previous, next []Session) []Session {
+	present := make(map[string]bool, len(next))
+	for _, session := range next {
+		present[sessionKey(session)] = true
+	}
+	// closed maps the key of a remaining session to the closed sessions that followed it. Closed
+	// sessions that followed none are keyed by "".
+	closed := map[string][]Session{}
+	anchor := ""
+	for _, session := range previous {
+		key := sessionKey(session)
+		if present[key] {
+			anchor = key
+			continue
+		}
+		if _, ok := session.(ClosedSession); !ok {
+			session = ClosedSession{Session: session}
+		}
+		closed[anchor] = append(closed[anchor], session)
+	}
+	if len(closed) == 0 {
+		return next
+	}
+	merged := make([]Session, 0, len(next)+len(previous))
+	merged = append(merged, closed[""]...)
+	for _, session := range next {
+		merged = append(merged, session)
+		merged = append(merged, closed[sessionKey(session)]...)
+		// Duplicates of a session must not repeat its closed followers.
+		delete(closed, sessionKey(session))
+	}
+	return merged
+}
+
+// sessionKey identifies a session across list updates.
+func sessionKey(session Session) string {
+	return session.Name() + "\x00" + session.Path()
+}
@@ -0,0 +1,38 @@
+package treemux
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMarkClosed(t *testing.T) {
+	a, b, c, d := fakeSession{name: "a"}, fakeSession{name: "b"}, fakeSession{name: "c"}, fakeSession{name: "d"}
+	tests := []struct {
+		name     string
+		previous []Session
+		next     []Session
+		want     []string
+	}{
+		{name: "unchanged", previous: []Session{a, b}, next: []Session{a, b}, want: []string{"a", "b"}},
+		{name: "added", previous: []Session{a}, next: []Session{a, b}, want: []string{"a", "b"}},
+		{name: "closed in place", previous: []Session{a, b, c}, next: []Session{a, c}, want: []string{"a", "b (closed)", "c"}},
+		{name: "closed first", previous: []Session{a, b}, next: []Session{d, b}, want: []string{"a (closed)", "d", "b"}},
+		{name: "stays closed", previous: []Session{a, ClosedSession{b}}, next: []Session{a}, want: []string{"a", "b (closed)"}},
+		{name: "reopened", previous: []Session{a, ClosedSession{b}}, next: []Session{a, b}, want: []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, session := range markClosed(tt.previous, tt.next) {
+				got = append(got, session.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if err := (ClosedSession{a}).Attach(); err == nil {
+		t.Fatal("expected attaching a closed session to fail")
+	}
+}
@@ -1,44 +1,202 @@
 package treemux
 
-// import (
-// 	"fmt"
-// 	"path/filepath"
-//
-// 	"github.com/ian-howell/treemux/internal/git"
-// )
-//
-// // resolveWorktree ensures a worktree exists and returns its path.
-// func resolveWorktree(branch string) (string, error) {
-// 	if branch == "" {
-// 		return "", fmt.Errorf("missing worktree branch")
-// 	}
-// 	if isWhitespaceOnly(branch) {
-// 		return "", fmt.Errorf("worktree branch cannot be blank")
-// 	}
-// 	root, err := git.RepositoryRoot("")
-// 	if err != nil {
-// 		return "", err
-// 	}
-// 	name := branch
-// 	path := filepath.Join(root, ".worktrees", name)
-// 	if err := git.EnsureWorktree(root, name, path); err != nil {
-// 		return "", err
-// 	}
-// 	return path, nil
-// }
-//
-// // worktreeDefaultPath returns the default worktree path for a branch.
-// func worktreeDefaultPath(branch string) (string, error) {
-// 	root, err := git.RepositoryRoot("")
-// 	if err != nil {
-// 		return "", err
-// 	}
-// 	if branch == "" {
-// 		return "", fmt.Errorf("missing worktree branch")
-// 	}
-// 	if isWhitespaceOnly(branch) {
-// 		return "", fmt.Errorf("worktree branch cannot be blank")
-// 	}
-// 	name := branch
-// 	return filepath.Join(root, ".worktrees", name), nil
-// }
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ian-howell/treemux/internal/git"
+)
+
+// RepoConfigFile is the per-repository configuration file, read from the main worktree root.
+const RepoConfigFile = ".treemux.yaml"
+
+// WorktreeSetup describes how a freshly created worktree is prepared.
+type WorktreeSetup struct {
+	// Copy lists files or directories, relative to the main worktree, copied into new worktrees.
+	Copy []string `yaml:"copy"`
+
+	// Symlink lists files or directories, relative to the main worktree, symlinked into new
+	// worktrees.
+	Symlink []string `yaml:"symlink"`
+
+	// Bootstrap lists shell commands run in a new worktree after files are copied.
+	Bootstrap []string `yaml:"bootstrap"`
+
+	// BootstrapWindow runs the bootstrap commands in a dedicated tmux window of the new session
+	// instead of before attaching.
+	BootstrapWindow bool `yaml:"bootstrap_window"`
+}
+
+// repoConfig is the layout of RepoConfigFile.
+type repoConfig struct {
+	Worktree WorktreeSetup `yaml:"worktree"`
+}
+
+// Worktree is a worktree resolved for a branch.
+type Worktree struct {
+	// Path is the worktree directory.
+	Path string
+
+	// MainRoot is the root of the repository's main worktree.
+	MainRoot string
+
+	// Created is whether the worktree was created by this call.
+	Created bool
+}
+
+// ResolveWorktree ensures a worktree exists for the branch in the repository containing the
+// current directory.
+func ResolveWorktree(branch string, fetch git.FetchOptions) (Worktree, error) {
+	worktree, err := PlanWorktree(branch)
+	if err != nil {
+		return Worktree{}, err
+	}
+	if err := git.EnsureWorktree(worktree.MainRoot, branch, worktree.Path, fetch); err != nil {
+		return Worktree{}, err
+	}
+	return worktree, nil
+}
+
+// PlanWorktree returns the worktree ResolveWorktree would use for a branch without fetching or
+// creating anything, as for a dry run. Created reports whether it would be created.
+func PlanWorktree(branch string) (Worktree, error) {
+	root, path, err := worktreeDefaultPath(branch)
+	if err != nil {
+		return Worktree{}, err
+	}
+	_, statErr := os.Stat(path)
+	return Worktree{
+		Path:     path,
+		MainRoot: root,
+		Created:  errors.Is(statErr, fs.ErrNotExist),
+	}, nil
+}
+
+// worktreeDefaultPath returns the root of the main worktree and the default worktree path for a
+// branch beneath it. Branch names may contain slashes, so the root cannot be derived from the path.
+func worktreeDefaultPath(branch string) (root, path string, err error) {
+	root, err = git.MainWorktreeRoot("")
+	if err != nil {
+		return "", "", err
+	}
+	if branch == "" {
+		return "", "", fmt.Errorf("missing worktree branch")
+	}
+	if strings.TrimSpace(branch) == "" {
+		return "", "", fmt.Errorf("worktree branch cannot be blank")
+	}
+	return root, filepath.Join(root, ".worktrees", branch), nil
+}
+
+// LoadWorktreeSetup reads the worktree setup from the repository's RepoConfigFile. A missing
+// file yields an empty setup.
+func LoadWorktreeSetup(mainRoot string) (WorktreeSetup, error) {
+	data, err := os.ReadFile(filepath.Join(mainRoot, RepoConfigFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return WorktreeSetup{}, nil
+	}
+	if err != nil {
+		return WorktreeSetup{}, fmt.Errorf("failed to read repo config: %w", err)
+	}
+	var config repoConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return WorktreeSetup{}, fmt.Errorf("failed to parse repo config: %w", err)
+	}
+	return config.Worktree, nil
+}
+
+// LinkFiles copies and symlinks the configured untracked files from the main worktree into the
+// worktree at path. Entries must be relative paths inside the repository. Every entry is attempted
+// and all failures are returned together.
+func (s WorktreeSetup) LinkFiles(mainRoot, path string) error {
+	var errs []error
+	for _, name := range s.Copy {
+		if !filepath.IsLocal(name) {
+			errs = append(errs, fmt.Errorf("failed to copy %s: not a relative path inside the repository", name))
+			continue
+		}
+		if err := copyPath(filepath.Join(mainRoot, name), filepath.Join(path, name)); err != nil {
+			errs = append(errs, fmt.Errorf("failed to copy %s: %w", name, err))
+		}
+	}
+	for _, name := range s.Symlink {
+		if !filepath.IsLocal(name) {
+			errs = append(errs, fmt.Errorf("failed to symlink %s: not a relative path inside the repository", name))
+			continue
+		}
+		dst := filepath.Join(path, name)
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			errs = append(errs, fmt.Errorf("failed to symlink %s: %w", name, err))
+			continue
+		}
+		if err := os.Symlink(filepath.Join(mainRoot, name), dst); err != nil {
+			errs = append(errs, fmt.Errorf("failed to symlink %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RunBootstrap runs the bootstrap commands in dir, stopping at the first failure.
+func (s WorktreeSetup) RunBootstrap(dir string, stdout, stderr io.Writer) error {
+	for _, command := range s.Bootstrap {
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Dir = dir
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("bootstrap command %q failed: %w", command, err)
+		}
+	}
+	return nil
+}
+
+// copyPath copies a file or directory tree from src to dst, preserving permissions.
+func copyPath(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		return copyFile(path, target, info.Mode().Perm())
+	})
+}
+
+// copyFile copies a single file from src to dst with the given permissions.
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -1,150 +1,227 @@
 package treemux
 
-// import (
-// 	"errors"
-// 	"fmt"
-// 	"os"
-// 	"os/exec"
-// 	"path/filepath"
-// 	"strings"
-// 	"testing"
-//
-// 	"github.com/ian-howell/treemux/internal/git"
-// )
-//
-// func TestWorktreeDefaultPath(t *testing.T) {
-// 	repo := newTestRepo(t)
-// 	cwd, err := os.Getwd()
-// 	if err != nil {
-// 		t.Fatalf("failed to get cwd: %v", err)
-// 	}
-// 	if err := os.Chdir(repo); err != nil {
-// 		t.Fatalf("failed to chdir: %v", err)
-// 	}
-// 	t.Cleanup(func() {
-// 		_ = os.Chdir(cwd)
-// 	})
-// 	root, err := worktreeDefaultPath("main")
-// 	if err != nil {
-// 		t.Fatalf("expected no error, got %v", err)
-// 	}
-// 	if filepath.Base(root) != "main" {
-// 		t.Fatalf("expected worktree path to end with branch name, got %q", root)
-// 	}
-// 	if !strings.Contains(root, string(filepath.Separator)+".worktrees"+string(filepath.Separator)) {
-// 		t.Fatalf("expected worktree path to include .worktrees, got %q", root)
-// 	}
-// }
-//
-// func TestEnsureBranchCreatesLocalBranch(t *testing.T) {
-// 	repo := newTestRepo(t)
-// 	branch := "example"
-// 	if err := git.EnsureBranch(repo, branch); err != nil {
-// 		t.Fatalf("expected branch creation, got %v", err)
-// 	}
-// 	if ok, err := hasLocalBranch(repo, branch); err != nil {
-// 		t.Fatalf("expected branch check to succeed, got %v", err)
-// 	} else if !ok {
-// 		t.Fatalf("expected local branch to exist")
-// 	}
-// }
-//
-// func TestEnsureBranchTracksRemoteBranch(t *testing.T) {
-// 	repo := newTestRepo(t)
-// 	branch := "remote-branch"
-// 	if err := createRemoteBranch(t, repo, branch); err != nil {
-// 		t.Fatalf("failed to create remote branch: %v", err)
-// 	}
-// 	if err := git.EnsureBranch(repo, branch); err != nil {
-// 		t.Fatalf("expected branch tracking, got %v", err)
-// 	}
-// 	if ok, err := hasLocalBranch(repo, branch); err != nil {
-// 		t.Fatalf("expected branch check to succeed, got %v", err)
-// 	} else if !ok {
-// 		t.Fatalf("expected local branch to exist")
-// 	}
-// }
-//
-// func newTestRepo(t *testing.T) string {
-// 	t.Helper()
-// 	dir := t.TempDir()
-// 	if err := runGit(dir, "init"); err != nil {
-// 		t.Fatalf("git init failed: %v", err)
-// 	}
-// 	if err := runGit(dir, "remote", "remove", "origin"); err != nil {
-// 		// ignore if origin doesn't exist
-// 	}
-// 	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("test"), 0o644); err != nil {
-// 		t.Fatalf("write file failed: %v", err)
-// 	}
-// 	if err := runGit(dir, "add", "."); err != nil {
-// 		t.Fatalf("git add failed: %v", err)
-// 	}
-// 	if err := runGit(dir, "commit", "-m", "init"); err != nil {
-// 		t.Fatalf("git commit failed: %v", err)
-// 	}
-// 	return dir
-// }
-//
-// func createRemoteBranch(t *testing.T, repo, branch string) error {
-// 	t.Helper()
-// 	remoteDir := t.TempDir()
-// 	if err := runGit(remoteDir, "init", "--bare"); err != nil {
-// 		return err
-// 	}
-// 	if err := runGit(repo, "remote", "add", "origin", remoteDir); err != nil {
-// 		return err
-// 	}
-// 	if err := runGit(repo, "checkout", "-b", branch); err != nil {
-// 		return err
-// 	}
-// 	if err := runGit(repo, "push", "-u", "origin", branch); err != nil {
-// 		return err
-// 	}
-// 	if err := runGit(repo, "checkout", "main"); err != nil {
-// 		return err
-// 	}
-// 	if err := runGit(repo, "branch", "-D", branch); err != nil {
-// 		return err
-// 	}
-// 	if err := runGit(repo, "fetch", "--all", "--prune"); err != nil {
-// 		return err
-// 	}
-// 	return nil
-// }
-//
-// func hasLocalBranch(repoRoot, branch string) (bool, error) {
-// 	cmd := exec.Command("git", "show-ref", "--verify", "refs/heads/"+branch)
-// 	cmd.Dir = repoRoot
-// 	if err := cmd.Run(); err != nil {
-// 		if exitErr, ok := errors.AsType[*exec.ExitError](err); ok {
-// 			stderr := string(exitErr.Stderr)
-// 			if strings.Contains(stderr, "not a valid ref") || strings.Contains(stderr, "fatal") {
-// 				return false, nil
-// 			}
-// 		}
-// 		return false, err
-// 	}
-// 	return true, nil
-// }
-//
-// func runGit(dir string, args ...string) error {
-// 	cmd := exec.Command("git", args...)
-// 	cmd.Dir = dir
-// 	cmd.Env = append(os.Environ(),
-// 		"GIT_AUTHOR_NAME=treemux",
-// 		"GIT_AUTHOR_EMAIL=treemux@example.com",
-// 		"GIT_COMMITTER_NAME=treemux",
-// 		"GIT_COMMITTER_EMAIL=treemux@example.com",
-// 	)
-// 	if err := cmd.Run(); err != nil {
-// 		if exitErr, ok := errors.AsType[*exec.ExitError](err); ok {
-// 			stderr := string(exitErr.Stderr)
-// 			if stderr != "" {
-// 				return fmt.Errorf("git %s: %s", strings.Join(args, " "), stderr)
-// 			}
-// 		}
-// 		return err
-// 	}
-// 	return nil
-// }
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ian-howell/treemux/internal/git"
+)
+
+func TestWorktreeDefaultPath(t *testing.T) {
+	repo := newTestRepo(t)
+	t.Chdir(repo)
+	root, path, err := worktreeDefaultPath("main")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if filepath.Base(path) != "main" {
+		t.Fatalf("expected worktree path to end with branch name, got %q", path)
+	}
+	if !strings.Contains(path, string(filepath.Separator)+".worktrees"+string(filepath.Separator)) {
+		t.Fatalf("expected worktree path to include .worktrees, got %q", path)
+	}
+	if want, _ := filepath.EvalSymlinks(repo); root != want && root != repo {
+		t.Fatalf("expected main root %q, got %q", repo, root)
+	}
+}
+
+func TestEnsureBranchCreatesLocalBranch(t *testing.T) {
+	repo := newTestRepo(t)
+	branch := "example"
+	if err := git.EnsureBranch(repo, branch, git.DefaultFetchOptions()); err != nil {
+		t.Fatalf("expected branch creation, got %v", err)
+	}
+	if ok, err := hasLocalBranch(repo, branch); err != nil {
+		t.Fatalf("expected branch check to succeed, got %v", err)
+	} else if !ok {
+		t.Fatalf("expected local branch to exist")
+	}
+}
+
+func TestEnsureBranchTracksRemoteBranch(t *testing.T) {
+	repo := newTestRepo(t)
+	branch := "remote-branch"
+	if err := createRemoteBranch(t, repo, branch); err != nil {
+		t.Fatalf("failed to create remote branch: %v", err)
+	}
+	if err := git.EnsureBranch(repo, branch, git.DefaultFetchOptions()); err != nil {
+		t.Fatalf("expected branch tracking, got %v", err)
+	}
+	if ok, err := hasLocalBranch(repo, branch); err != nil {
+		t.Fatalf("expected branch check to succeed, got %v", err)
+	} else if !ok {
+		t.Fatalf("expected local branch to exist")
+	}
+}
+
+func TestResolveWorktreeAppliesSetup(t *testing.T) {
+	repo := newTestRepo(t)
+	t.Chdir(repo)
+	files := map[string]string{
+		".env":               "SECRET=1",
+		"certs/local.pem":    "cert",
+		RepoConfigFile:       "worktree:\n  copy: [.env, certs]\n  symlink: [deps]\n  bootstrap: [\"touch bootstrapped\"]\n",
+		"deps/installed.txt": "deps",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(repo, name)), 0o755); err != nil {
+			t.Fatalf("mkdir failed: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("write file failed: %v", err)
+		}
+	}
+
+	worktree, err := ResolveWorktree("feature", git.FetchOptions{Policy: git.FetchNever})
+	if err != nil {
+		t.Fatalf("expected worktree, got %v", err)
+	}
+	if !worktree.Created {
+		t.Fatalf("expected worktree to be reported as created")
+	}
+	setup, err := LoadWorktreeSetup(worktree.MainRoot)
+	if err != nil {
+		t.Fatalf("expected setup, got %v", err)
+	}
+	if err := setup.LinkFiles(worktree.MainRoot, worktree.Path); err != nil {
+		t.Fatalf("expected files to be linked, got %v", err)
+	}
+	if err := setup.RunBootstrap(worktree.Path, nil, nil); err != nil {
+		t.Fatalf("expected bootstrap to succeed, got %v", err)
+	}
+	for _, name := range []string{".env", "certs/local.pem", "deps/installed.txt", "bootstrapped"} {
+		if _, err := os.Stat(filepath.Join(worktree.Path, name)); err != nil {
+			t.Fatalf("expected %s in new worktree: %v", name, err)
+		}
+	}
+	if info, err := os.Lstat(filepath.Join(worktree.Path, "deps")); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("expected deps to be a symlink, got %v", err)
+	}
+
+	again, err := ResolveWorktree("feature", git.FetchOptions{Policy: git.FetchNever})
+	if err != nil || again.Created {
+		t.Fatalf("expected existing worktree to be reused, got %+v, %v", again, err)
+	}
+}
+
+func TestResolveWorktreeWithSlashedBranch(t *testing.T) {
+	repo := newTestRepo(t)
+	t.Chdir(repo)
+	worktree, err := ResolveWorktree("feature/foo", git.FetchOptions{Policy: git.FetchNever})
+	if err != nil {
+		t.Fatalf("expected worktree, got %v", err)
+	}
+	if filepath.Base(worktree.MainRoot) != filepath.Base(repo) {
+		t.Fatalf("expected main root %q, got %q", repo, worktree.MainRoot)
+	}
+	if want := filepath.Join(worktree.MainRoot, ".worktrees", "feature", "foo"); worktree.Path != want {
+		t.Fatalf("expected worktree at %q, got %q", want, worktree.Path)
+	}
+}
+
+func TestLinkFilesRejectsPathsOutsideRepository(t *testing.T) {
+	mainRoot, path := t.TempDir(), t.TempDir()
+	outside := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(outside, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	setup := WorktreeSetup{Copy: []string{outside, "../secret"}, Symlink: []string{"/etc", "a/../../b"}}
+	err := setup.LinkFiles(mainRoot, path)
+	for _, name := range []string{outside, "../secret", "/etc", "a/../../b"} {
+		if err == nil || !strings.Contains(err.Error(), name+": not a relative path") {
+			t.Fatalf("expected %s to be rejected, got %v", name, err)
+		}
+	}
+	if entries, _ := os.ReadDir(path); len(entries) != 0 {
+		t.Fatalf("expected nothing linked, got %v", entries)
+	}
+}
+
+func TestLinkFilesReportsEveryFailure(t *testing.T) {
+	setup := WorktreeSetup{Copy: []string{"missing-a", "missing-b"}}
+	err := setup.LinkFiles(t.TempDir(), t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "missing-a") || !strings.Contains(err.Error(), "missing-b") {
+		t.Fatalf("expected both missing files to be reported, got %v", err)
+	}
+}
+
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("TREEMUX_STATE_DIR", t.TempDir())
+	dir := t.TempDir()
+	if err := runGit(dir, "init", "--initial-branch=main"); err != nil {
+		t.Fatalf("git init failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("test"), 0o644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	if err := runGit(dir, "add", "."); err != nil {
+		t.Fatalf("git add failed: %v", err)
+	}
+	if err := runGit(dir, "commit", "-m", "init"); err != nil {
+		t.Fatalf("git commit failed: %v", err)
+	}
+	return dir
+}
+
+func createRemoteBranch(t *testing.T, repo, branch string) error {
+	t.Helper()
+	remoteDir := t.TempDir()
+	if err := runGit(remoteDir, "init", "--bare"); err != nil {
+		return err
+	}
+	if err := runGit(repo, "remote", "add", "origin", remoteDir); err != nil {
+		return err
+	}
+	if err := runGit(repo, "checkout", "-b", branch); err != nil {
+		return err
+	}
+	if err := runGit(repo, "push", "-u", "origin", branch); err != nil {
+		return err
+	}
+	if err := runGit(repo, "checkout", "main"); err != nil {
+		return err
+	}
+	if err := runGit(repo, "branch", "-D", branch); err != nil {
+		return err
+	}
+	if err := runGit(repo, "fetch", "--all", "--prune"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func hasLocalBranch(repoRoot, branch string) (bool, error) {
+	cmd := exec.Command("git", "show-ref", "--verify", "--quiet", "refs/heads/"+branch)
+	cmd.Dir = repoRoot
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := errors.AsType[*exec.ExitError](err); ok && exitErr.ExitCode() == 1 {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func runGit(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=treemux",
+		"GIT_AUTHOR_EMAIL=treemux@example.com",
+		"GIT_COMMITTER_NAME=treemux",
+		"GIT_COMMITTER_EMAIL=treemux@example.com",
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %s", strings.Join(args, " "), output)
+	}
+	return nil
+}